@@ -0,0 +1,98 @@
+/*
+Command lmdb_check verifies the consistency of an LMDB environment.  Every
+database in the environment is traversed to check the order of its items and
+their number, and the pages used by the databases and freelist are accounted
+for.  Any inconsistencies are written to the standard output and lmdb_check
+exits with a non-zero status.
+
+	lmdb_check [-v] [-maxdbs N] path
+
+Databases are compared using the default comparison functions for their flags
+so environments containing databases which use custom comparison functions
+cannot be verified by lmdb_check.  For more information about the checks
+performed see the documentation for lmdb.Txn.Verify.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/bmatsuo/lmdb-go/internal/lmdbcmd"
+	"github.com/bmatsuo/lmdb-go/lmdb"
+)
+
+func main() {
+	opt := &Options{}
+	flag.IntVar(&opt.MaxDBs, "maxdbs", 1024, "The maximum number of named databases in the environment.")
+	flag.BoolVar(&opt.Verbose, "v", false, "Display a summary of the environment after it is verified.")
+	flag.Parse()
+
+	lmdbcmd.PrintVersion()
+
+	if flag.NArg() > 1 {
+		log.Fatalf("too many arguments specified")
+	}
+	if flag.NArg() == 0 {
+		log.Fatalf("missing argument")
+	}
+	opt.Path = flag.Arg(0)
+
+	r, err := checkEnv(opt)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, e := range r.Errors {
+		fmt.Println(e)
+	}
+	if opt.Verbose {
+		printReport(r)
+	}
+	if !r.OK() {
+		os.Exit(1)
+	}
+}
+
+// Options contain the command line options for an lmdb_check command.
+type Options struct {
+	Path    string
+	MaxDBs  int
+	Verbose bool
+}
+
+func checkEnv(opt *Options) (*lmdb.VerifyReport, error) {
+	env, err := lmdb.NewEnv()
+	if err != nil {
+		return nil, err
+	}
+	defer env.Close()
+	err = env.SetMaxDBs(opt.MaxDBs)
+	if err != nil {
+		return nil, err
+	}
+	err = env.Open(opt.Path, lmdbcmd.OpenFlag()|lmdb.Readonly, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return env.Verify()
+}
+
+func printReport(r *lmdb.VerifyReport) {
+	fmt.Println("Databases:", len(r.DBs))
+	for _, name := range r.DBs {
+		fmt.Printf("  %q\n", name)
+	}
+	fmt.Println("Entries:", r.Entries)
+	fmt.Println("Pages used:", r.Pages)
+	fmt.Println("Free pages:", r.FreePages)
+	if r.LastPNO >= 0 {
+		fmt.Println("Last page:", r.LastPNO)
+	}
+	if len(r.Errors) == 0 {
+		fmt.Println("No errors")
+	} else {
+		fmt.Println("Errors:", len(r.Errors))
+	}
+}
@@ -9,8 +9,6 @@ about, run lmdb_stat with the -h flag.
 */
 package main
 
-import "C"
-
 import (
 	"bufio"
 	"flag"
@@ -18,9 +16,7 @@ import (
 	"io"
 	"log"
 	"os"
-	"reflect"
 	"strings"
-	"unsafe"
 
 	"github.com/bmatsuo/lmdb-go/internal/lmdbcmd"
 	"github.com/bmatsuo/lmdb-go/lmdb"
@@ -35,7 +31,7 @@ func main() {
 	flag.BoolVar(&opt.PrintFreeFull, "fff", false, "Display freelist information")
 	flag.BoolVar(&opt.PrintReaders, "r", false, strings.Join([]string{
 		"Display information about the environment reader table.",
-		"Shows the process ID, thread ID, and transaction ID for each active reader slot.",
+		"Shows the process ID, thread ID, transaction ID, and transaction lag for each active reader slot.",
 	}, "  "))
 	flag.BoolVar(&opt.PrintReadersCheck, "rr", false, strings.Join([]string{
 		"Implies -r.",
@@ -208,16 +204,42 @@ func doPrintReaders(env *lmdb.Env, opt *Options) error {
 }
 
 func printReaders(env *lmdb.Env, w io.Writer, opt *Options) error {
-	return env.ReaderList(func(msg string) error {
-		_, err := fmt.Fprint(w, msg)
+	readers, err := env.Readers()
+	if err != nil {
 		return err
-	})
+	}
+	if len(readers) == 0 {
+		_, err = fmt.Fprintln(w, "(no active readers)")
+		return err
+	}
+	info, err := env.Info()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%10s %14s %10s %10s\n", "pid", "thread", "txnid", "lag")
+	if err != nil {
+		return err
+	}
+	for _, r := range readers {
+		txnid, lag := "-", "-"
+		if r.Active() {
+			txnid = fmt.Sprint(r.TxnID)
+			lag = fmt.Sprint(r.Lag(info.LastTxnID))
+		}
+		var stale string
+		if r.Stale {
+			stale = " (stale)"
+		}
+		_, err = fmt.Fprintf(w, "%10d %14x %10s %10s%s\n", r.PID, r.ThreadID, txnid, lag, stale)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func doPrintFree(env *lmdb.Env, opt *Options) error {
 	return env.View(func(txn *lmdb.Txn) (err error) {
-		txn.RawRead = true
-
 		fmt.Println("Freelist Status")
 
 		stat, err := txn.Stat(0)
@@ -226,45 +248,22 @@ func doPrintFree(env *lmdb.Env, opt *Options) error {
 		}
 		printStat(stat, opt)
 
-		var numpages int64
-		s := lmdbscan.New(txn, 0)
-		defer s.Close()
-		for s.Scan() {
-			key := s.Key()
-			data := s.Val()
-			txid := *(*C.size_t)(unsafe.Pointer(&key[0]))
-			ipages := int64(*(*C.size_t)(unsafe.Pointer(&data[0])))
-			numpages += ipages
-			if opt.PrintFreeSummary || opt.PrintFreeFull {
+		var printTxn func(ftxn lmdb.FreelistTxn, pages []uint64) error
+		if opt.PrintFreeSummary || opt.PrintFreeFull {
+			printTxn = func(ftxn lmdb.FreelistTxn, pages []uint64) error {
 				bad := ""
-				hdr := reflect.SliceHeader{
-					Data: uintptr(unsafe.Pointer(&data[0])),
-					Len:  int(ipages) + 1,
-					Cap:  int(ipages) + 1,
-				}
-				pages := *(*[]C.size_t)(unsafe.Pointer(&hdr))
-				pages = pages[1:]
-				var span C.size_t
-				prev := C.size_t(1)
-				for i := ipages - 1; i >= 0; i-- {
-					pg := pages[i]
-					if pg < prev {
+				for j := 1; j < len(pages); j++ {
+					if pages[j] >= pages[j-1] {
 						bad = " [bad sequence]"
 					}
-					prev = pg
-					pg += span
-					for i >= int64(span) && pages[i-int64(span)] == pg {
-						span++
-						pg++
-					}
 				}
-				fmt.Printf("    Transaction %d, %d pages, maxspan %d%s\n", txid, ipages, span, bad)
+				fmt.Printf("    Transaction %d, %d pages, maxspan %d%s\n", ftxn.TxnID, ftxn.Pages, ftxn.MaxSpan, bad)
 
 				if opt.PrintFreeFull {
-					for j := ipages - 1; j >= 0; {
+					for j := len(pages) - 1; j >= 0; {
 						pg := pages[j]
 						j--
-						span := C.size_t(1)
+						span := uint64(1)
 						for j >= 0 && pages[j] == pg+span {
 							j--
 							span++
@@ -276,14 +275,19 @@ func doPrintFree(env *lmdb.Env, opt *Options) error {
 						}
 					}
 				}
+				return nil
 			}
 		}
-		err = s.Err()
+		free, err := txn.FreelistStatFunc(printTxn)
 		if err != nil {
 			return err
 		}
 
-		fmt.Println("  Free pages:", numpages)
+		fmt.Println("  Free pages:", free.FreePages)
+		if opt.PrintFreeSummary || opt.PrintFreeFull {
+			fmt.Println("  Max span:", free.MaxSpan)
+			fmt.Printf("  Fragmentation: %.3f\n", free.Fragmentation)
+		}
 
 		return nil
 	})
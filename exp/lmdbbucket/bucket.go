@@ -0,0 +1,335 @@
+/*
+Package lmdbbucket provides nested buckets of keys, in the style of BoltDB,
+within a single LMDB database.
+
+Each Bucket is identified by a prefix which encodes its path from the root
+bucket of the database.  The items of a Bucket and all of its nested buckets
+are stored contiguously under its prefix, so buckets can be scanned with
+lmdbscan and deleted with a single pass of a cursor, and any number of
+buckets may be created without increasing lmdb.Env.SetMaxDBs.
+
+Keys and bucket names are separate namespaces, so a Bucket may contain a key
+and a nested bucket with the same name.  Unlike the keys of a database, the
+keys of a Bucket may be empty.  Because prefixes are stored in every key, the
+maximum key size (see lmdb.Env.MaxKeySize) limits the combined length of a
+key and the names of the buckets containing it.
+
+The database containing buckets must use the default key comparison and must
+not be written to except through this package.
+
+A Bucket is only valid for the lifetime of the transaction it was obtained
+from.
+*/
+package lmdbbucket
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/bmatsuo/lmdb-go/lmdb"
+	"github.com/bmatsuo/lmdb-go/lmdbscan"
+)
+
+// ErrBucketExists is returned by Bucket.CreateBucket when the bucket already
+// exists.
+var ErrBucketExists = errors.New("lmdbbucket: bucket already exists")
+
+// Keys within a bucket's prefix begin with a tag distinguishing nested
+// buckets from items.  A nested bucket is stored as a marker item whose key
+// is the nested bucket's prefix: the parent prefix, tagBucket, the length of
+// the name as a uvarint, and the name.  The length makes prefixes of sibling
+// buckets disjoint.
+const (
+	tagBucket byte = 0x00
+	tagItem   byte = 0x01
+)
+
+// Bucket is a collection of items and nested buckets.
+type Bucket struct {
+	txn    *lmdb.Txn
+	dbi    lmdb.DBI
+	prefix []byte
+}
+
+// Root returns the root bucket of dbi.  The root bucket always exists.
+func Root(txn *lmdb.Txn, dbi lmdb.DBI) *Bucket {
+	return &Bucket{txn: txn, dbi: dbi}
+}
+
+// child returns the prefix of the nested bucket name.
+func (b *Bucket) child(name []byte) []byte {
+	var n [binary.MaxVarintLen64]byte
+	ln := binary.PutUvarint(n[:], uint64(len(name)))
+	p := make([]byte, 0, len(b.prefix)+1+ln+len(name))
+	p = append(p, b.prefix...)
+	p = append(p, tagBucket)
+	p = append(p, n[:ln]...)
+	return append(p, name...)
+}
+
+// item returns the key of the item k in b.
+func (b *Bucket) item(k []byte) []byte {
+	p := make([]byte, 0, len(b.prefix)+1+len(k))
+	p = append(p, b.prefix...)
+	p = append(p, tagItem)
+	return append(p, k...)
+}
+
+// itemPrefix returns the prefix shared by all the items of b.
+func (b *Bucket) itemPrefix() []byte {
+	return b.item(nil)
+}
+
+// Bucket returns the nested bucket name.  If the bucket does not exist an
+// error is returned for which lmdb.IsNotFound returns true.
+func (b *Bucket) Bucket(name []byte) (*Bucket, error) {
+	p := b.child(name)
+	_, err := b.txn.Get(b.dbi, p)
+	if err != nil {
+		return nil, err
+	}
+	return &Bucket{txn: b.txn, dbi: b.dbi, prefix: p}, nil
+}
+
+// CreateBucket creates and returns the nested bucket name.  If the bucket
+// already exists ErrBucketExists is returned.
+func (b *Bucket) CreateBucket(name []byte) (*Bucket, error) {
+	p := b.child(name)
+	err := b.txn.Put(b.dbi, p, nil, lmdb.NoOverwrite)
+	if lmdb.IsErrno(err, lmdb.KeyExist) {
+		return nil, ErrBucketExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Bucket{txn: b.txn, dbi: b.dbi, prefix: p}, nil
+}
+
+// CreateBucketIfNotExists returns the nested bucket name, creating it if it
+// does not exist.
+func (b *Bucket) CreateBucketIfNotExists(name []byte) (*Bucket, error) {
+	child, err := b.CreateBucket(name)
+	if err == ErrBucketExists {
+		return b.Bucket(name)
+	}
+	return child, err
+}
+
+// DeleteBucket deletes the nested bucket name along with all of its items and
+// nested buckets.  If the bucket does not exist an error is returned for which
+// lmdb.IsNotFound returns true.
+func (b *Bucket) DeleteBucket(name []byte) error {
+	p := b.child(name)
+	cur, err := b.txn.OpenCursor(b.dbi)
+	if err != nil {
+		return err
+	}
+	defer cur.Close()
+
+	// The marker is the first key with the bucket's prefix.
+	k, _, err := cur.Get(p, nil, lmdb.Set)
+	if err != nil {
+		return err
+	}
+	for bytes.HasPrefix(k, p) {
+		err = cur.Del(0)
+		if err != nil {
+			return err
+		}
+		// Next returns the key which followed the deleted marker or item.
+		k, _, err = cur.Get(nil, nil, lmdb.Next)
+		if lmdb.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForEachBucket calls fn with the name of each nested bucket of b, in the
+// order of their encoded prefixes.  Names shorter than 128 bytes are ordered
+// by their lengths and then their bytes, but longer names are not ordered by
+// length because their lengths are encoded as multiple byte uvarints.  If fn
+// returns an error iteration stops and the error is returned.
+func (b *Bucket) ForEachBucket(fn func(name []byte) error) error {
+	cur, err := b.txn.OpenCursor(b.dbi)
+	if err != nil {
+		return err
+	}
+	defer cur.Close()
+
+	start := append(append([]byte(nil), b.prefix...), tagBucket)
+	k, _, err := cur.Get(start, nil, lmdb.SetRange)
+	for err == nil && bytes.HasPrefix(k, start) {
+		n, ln := binary.Uvarint(k[len(start):])
+		if ln <= 0 || uint64(len(k)-len(start)-ln) != n {
+			return errors.New("lmdbbucket: invalid bucket marker")
+		}
+		err = fn(k[len(start)+ln:])
+		if err != nil {
+			return err
+		}
+		end := lmdbscan.PrefixEnd(k)
+		if end == nil {
+			return nil
+		}
+		k, _, err = cur.Get(end, nil, lmdb.SetRange)
+	}
+	if lmdb.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// Get returns the value of item k in b.  If b does not contain k an error is
+// returned for which lmdb.IsNotFound returns true.
+func (b *Bucket) Get(k []byte) ([]byte, error) {
+	return b.txn.Get(b.dbi, b.item(k))
+}
+
+// Put stores an item in b.
+func (b *Bucket) Put(k, v []byte) error {
+	return b.txn.Put(b.dbi, b.item(k), v, 0)
+}
+
+// Del deletes item k from b.  If b does not contain k an error is returned for
+// which lmdb.IsNotFound returns true.
+func (b *Bucket) Del(k []byte) error {
+	return b.txn.Del(b.dbi, b.item(k), nil)
+}
+
+// ForEach calls fn with each item in b, in key order.  Items of nested
+// buckets are not included.  If fn returns an error iteration stops and the
+// error is returned.
+func (b *Bucket) ForEach(fn func(k, v []byte) error) error {
+	s := b.Scan()
+	defer s.Close()
+	for s.Scan() {
+		err := fn(s.Key(), s.Val())
+		if err != nil {
+			return err
+		}
+	}
+	return s.Err()
+}
+
+// Scan returns a Scanner for the items in b.  When the Scanner is no longer
+// needed its Close method must be called.
+func (b *Bucket) Scan() *Scanner {
+	p := b.itemPrefix()
+	return &Scanner{
+		s: lmdbscan.NewRange(b.txn, b.dbi, &lmdbscan.Range{Prefix: p}),
+		n: len(p),
+	}
+}
+
+// Scanner scans the items in a Bucket.  Scanner is an lmdbscan.Source, so
+// buckets may be merged and joined with the scanners of that package.
+type Scanner struct {
+	s *lmdbscan.RangeScanner
+	n int
+}
+
+// Scan advances s to the next item in the bucket.
+func (s *Scanner) Scan() bool {
+	return s.s.Scan()
+}
+
+// Key returns the key of the current item, without the bucket's prefix.
+func (s *Scanner) Key() []byte {
+	k := s.s.Key()
+	if k == nil {
+		return nil
+	}
+	return k[s.n:]
+}
+
+// Val returns the value of the current item.
+func (s *Scanner) Val() []byte {
+	return s.s.Val()
+}
+
+// Err returns a non-nil error if and only if the previous call to Scan
+// resulted in an error other than lmdb.ErrNotFound.
+func (s *Scanner) Err() error {
+	return s.s.Err()
+}
+
+// Close closes the cursor underlying s.
+func (s *Scanner) Close() {
+	s.s.Close()
+}
+
+// Cursor returns a Cursor for the items in b.  When the Cursor is no longer
+// needed its Close method must be called.
+func (b *Bucket) Cursor() (*Cursor, error) {
+	cur, err := b.txn.OpenCursor(b.dbi)
+	if err != nil {
+		return nil, err
+	}
+	return &Cursor{cur: cur, prefix: b.itemPrefix()}, nil
+}
+
+// Cursor positions itself at the items in a Bucket.  Items are returned
+// without the bucket's prefix.  When a Cursor moves outside of its bucket an
+// error is returned for which lmdb.IsNotFound returns true.
+type Cursor struct {
+	cur    *lmdb.Cursor
+	prefix []byte
+}
+
+// First moves c to the first item in the bucket.
+func (c *Cursor) First() (k, v []byte, err error) {
+	return c.get(c.prefix, lmdb.SetRange)
+}
+
+// Last moves c to the last item in the bucket.
+func (c *Cursor) Last() (k, v []byte, err error) {
+	end := lmdbscan.PrefixEnd(c.prefix)
+	if end != nil {
+		_, _, err = c.cur.Get(end, nil, lmdb.SetRange)
+		if err == nil {
+			return c.get(nil, lmdb.Prev)
+		}
+		if !lmdb.IsNotFound(err) {
+			return nil, nil, err
+		}
+	}
+	return c.get(nil, lmdb.Last)
+}
+
+// Next moves c to the next item in the bucket.
+func (c *Cursor) Next() (k, v []byte, err error) {
+	return c.get(nil, lmdb.Next)
+}
+
+// Prev moves c to the previous item in the bucket.
+func (c *Cursor) Prev() (k, v []byte, err error) {
+	return c.get(nil, lmdb.Prev)
+}
+
+// Seek moves c to the first item in the bucket with a key greater than or
+// equal to seek.
+func (c *Cursor) Seek(seek []byte) (k, v []byte, err error) {
+	key := append(append([]byte(nil), c.prefix...), seek...)
+	return c.get(key, lmdb.SetRange)
+}
+
+func (c *Cursor) get(setkey []byte, op uint) (k, v []byte, err error) {
+	k, v, err = c.cur.Get(setkey, nil, op)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !bytes.HasPrefix(k, c.prefix) {
+		return nil, nil, lmdb.NotFound
+	}
+	return k[len(c.prefix):], v, nil
+}
+
+// Close closes the cursor underlying c.
+func (c *Cursor) Close() {
+	c.cur.Close()
+}
@@ -0,0 +1,292 @@
+package lmdbbucket
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/bmatsuo/lmdb-go/internal/lmdbtest"
+	"github.com/bmatsuo/lmdb-go/lmdb"
+)
+
+func items(b *Bucket) (items []string, err error) {
+	err = b.ForEach(func(k, v []byte) error {
+		items = append(items, fmt.Sprintf("%s=%s", k, v))
+		return nil
+	})
+	return items, err
+}
+
+func buckets(b *Bucket) (names []string, err error) {
+	err = b.ForEachBucket(func(name []byte) error {
+		names = append(names, string(name))
+		return nil
+	})
+	return names, err
+}
+
+func TestBucket(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		root := Root(txn, dbi)
+		err = root.Put([]byte("a"), []byte("root"))
+		if err != nil {
+			return err
+		}
+		for _, name := range []string{"a", "ab", "b"} {
+			b, err := root.CreateBucket([]byte(name))
+			if err != nil {
+				return err
+			}
+			for _, k := range []string{"", "x", "y"} {
+				err = b.Put([]byte(k), []byte(name))
+				if err != nil {
+					return err
+				}
+			}
+			nested, err := b.CreateBucket([]byte("nested"))
+			if err != nil {
+				return err
+			}
+			err = nested.Put([]byte("x"), []byte(name+"/nested"))
+			if err != nil {
+				return err
+			}
+		}
+		_, err = root.CreateBucket([]byte("a"))
+		if err != ErrBucketExists {
+			t.Errorf("unexpected error: %v (!= %v)", err, ErrBucketExists)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		root := Root(txn, dbi)
+		names, err := buckets(root)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(names, []string{"a", "b", "ab"}) {
+			t.Errorf("unexpected buckets: %q", names)
+		}
+		kv, err := items(root)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(kv, []string{"a=root"}) {
+			t.Errorf("unexpected items: %q", kv)
+		}
+
+		a, err := root.Bucket([]byte("a"))
+		if err != nil {
+			return err
+		}
+		kv, err = items(a)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(kv, []string{"=a", "x=a", "y=a"}) {
+			t.Errorf("unexpected items: %q", kv)
+		}
+		nested, err := a.Bucket([]byte("nested"))
+		if err != nil {
+			return err
+		}
+		v, err := nested.Get([]byte("x"))
+		if err != nil {
+			return err
+		}
+		if string(v) != "a/nested" {
+			t.Errorf("unexpected value: %q", v)
+		}
+		_, err = root.Bucket([]byte("c"))
+		if !lmdb.IsNotFound(err) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		root := Root(txn, dbi)
+		err = root.DeleteBucket([]byte("a"))
+		if err != nil {
+			return err
+		}
+		err = root.DeleteBucket([]byte("a"))
+		if !lmdb.IsNotFound(err) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		// The last bucket in the database.
+		err = root.DeleteBucket([]byte("ab"))
+		if err != nil {
+			return err
+		}
+		b, err := root.Bucket([]byte("b"))
+		if err != nil {
+			return err
+		}
+		return b.Del([]byte("x"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		root := Root(txn, dbi)
+		names, err := buckets(root)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(names, []string{"b"}) {
+			t.Errorf("unexpected buckets: %q", names)
+		}
+		b, err := root.Bucket([]byte("b"))
+		if err != nil {
+			return err
+		}
+		kv, err := items(b)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(kv, []string{"=b", "y=b"}) {
+			t.Errorf("unexpected items: %q", kv)
+		}
+		stat, err := txn.Stat(dbi)
+		if err != nil {
+			return err
+		}
+		// The root item, and bucket b with two items and a nested bucket with
+		// one item.
+		if stat.Entries != 6 {
+			t.Errorf("unexpected entries: %d (!= 6)", stat.Entries)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCursor(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		root := Root(txn, dbi)
+		for _, name := range []string{"a", "b", "c"} {
+			b, err := root.CreateBucket([]byte(name))
+			if err != nil {
+				return err
+			}
+			for _, k := range []string{"k1", "k2", "k3"} {
+				err = b.Put([]byte(k), []byte(name))
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		for _, name := range []string{"a", "c"} {
+			b, err := Root(txn, dbi).Bucket([]byte(name))
+			if err != nil {
+				return err
+			}
+			c, err := b.Cursor()
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			var keys []string
+			for k, _, err := c.First(); err == nil; k, _, err = c.Next() {
+				keys = append(keys, string(k))
+			}
+			for k, _, err := c.Last(); err == nil; k, _, err = c.Prev() {
+				keys = append(keys, string(k))
+			}
+			k, v, err := c.Seek([]byte("k2"))
+			if err != nil {
+				return err
+			}
+			keys = append(keys, string(k)+"="+string(v))
+			exp := []string{"k1", "k2", "k3", "k3", "k2", "k1", "k2=" + name}
+			if !reflect.DeepEqual(keys, exp) {
+				t.Errorf("%s: unexpected keys: %q (!= %q)", name, keys, exp)
+			}
+			_, _, err = c.Seek([]byte("k4"))
+			if !lmdb.IsNotFound(err) {
+				t.Errorf("%s: unexpected error: %v", name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBucket_ForEachBucket_long(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The uvarint encoding of 256 sorts before that of 129.
+	var lens []int
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		root := Root(txn, dbi)
+		for _, n := range []int{129, 1, 256, 127} {
+			_, err = root.CreateBucket(bytes.Repeat([]byte("x"), n))
+			if err != nil {
+				return err
+			}
+		}
+		return root.ForEachBucket(func(name []byte) error {
+			lens = append(lens, len(name))
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(lens, []int{1, 127, 256, 129}) {
+		t.Errorf("unexpected name lengths: %v", lens)
+	}
+}
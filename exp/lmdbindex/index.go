@@ -0,0 +1,346 @@
+/*
+Package lmdbindex maintains secondary indexes of LMDB databases.
+
+An Index maps keys extracted from the items of a source database to the keys
+of the items they were extracted from.  Index entries are stored in a
+separate database, which must be opened with the lmdb.DupSort flag, with an
+index key as the key of each entry and a source key as its value.
+
+	byEmail := &lmdbindex.Index{
+		Source: users,
+		DBI:    usersByEmail,
+		Extract: func(k, v []byte) ([][]byte, error) {
+			return [][]byte{emailOf(v)}, nil
+		},
+	}
+	idx := lmdbindex.New(byEmail)
+
+Writes to source databases made through the Put and Del methods of a Set
+update all of the Set's indexes within the same transaction, so indexes
+cannot drift from their sources unless the sources are written to directly.
+If an error is returned by Put or Del the transaction may have been partially
+modified and must be aborted, which lmdb.Env.Update does when its function
+returns an error.
+
+Indexes can be built for existing data, or repaired, with Rebuild, and
+checked for consistency with Verify.
+
+Source databases must not have the lmdb.DupSort flag.
+*/
+package lmdbindex
+
+import (
+	"fmt"
+
+	"github.com/bmatsuo/lmdb-go/lmdb"
+	"github.com/bmatsuo/lmdb-go/lmdbscan"
+)
+
+// Extractor returns the index keys of an item in a source database.  An
+// Extractor may return no keys, in which case the item is not indexed.  The
+// key and value passed to an Extractor must not be retained after it returns.
+type Extractor func(key, val []byte) ([][]byte, error)
+
+// Index is a secondary index of the items in a source database.
+type Index struct {
+	Source  lmdb.DBI  // Database containing the indexed items
+	DBI     lmdb.DBI  // Database with the DupSort flag containing the index
+	Extract Extractor // Computes the index keys of an item
+}
+
+// Get returns the keys of the items in the source database with index key
+// ikey, in ascending order.  Get returns no keys and a nil error if no item
+// has index key ikey.
+func (idx *Index) Get(txn *lmdb.Txn, ikey []byte) ([][]byte, error) {
+	cur, err := txn.OpenCursor(idx.DBI)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close()
+
+	var keys [][]byte
+	_, k, err := cur.Get(ikey, nil, lmdb.Set)
+	for err == nil {
+		if txn.RawRead {
+			k = append([]byte(nil), k...)
+		}
+		keys = append(keys, k)
+		_, k, err = cur.Get(nil, nil, lmdb.NextDup)
+	}
+	if !lmdb.IsNotFound(err) {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// extract returns copies of the index keys of an item so that they remain
+// valid after the database is modified.
+func (idx *Index) extract(key, val []byte) ([][]byte, error) {
+	ikeys, err := idx.Extract(key, val)
+	if err != nil {
+		return nil, err
+	}
+	for i := range ikeys {
+		ikeys[i] = append([]byte(nil), ikeys[i]...)
+	}
+	return ikeys, nil
+}
+
+// add inserts index entries for the item with the given key.  Entries which
+// already exist are ignored.
+func (idx *Index) add(txn *lmdb.Txn, key []byte, ikeys [][]byte) error {
+	for _, ikey := range ikeys {
+		err := txn.Put(idx.DBI, ikey, key, lmdb.NoDupData)
+		if err != nil && !lmdb.IsErrno(err, lmdb.KeyExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// remove deletes index entries for the item with the given key.  Entries
+// which do not exist are ignored.
+func (idx *Index) remove(txn *lmdb.Txn, key []byte, ikeys [][]byte) error {
+	for _, ikey := range ikeys {
+		err := txn.Del(idx.DBI, ikey, key)
+		if err != nil && !lmdb.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rebuild removes all entries from idx and indexes every item in the source
+// database.  Rebuild must be called with an update transaction.
+func (idx *Index) Rebuild(txn *lmdb.Txn) error {
+	err := txn.Drop(idx.DBI, false)
+	if err != nil {
+		return err
+	}
+
+	s := lmdbscan.New(txn, idx.Source)
+	defer s.Close()
+	for s.Scan() {
+		ikeys, err := idx.extract(s.Key(), s.Val())
+		if err != nil {
+			return err
+		}
+		err = idx.add(txn, s.Key(), ikeys)
+		if err != nil {
+			return err
+		}
+	}
+	return s.Err()
+}
+
+// Problem describes an inconsistency between an Index and its source
+// database.
+type Problem struct {
+	IndexKey []byte
+	Key      []byte
+
+	// Missing is true if the index does not contain an entry for an item in
+	// the source database.  Otherwise the index contains an entry for an item
+	// which does not exist or does not have the index key.
+	Missing bool
+}
+
+func (p *Problem) String() string {
+	if p.Missing {
+		return fmt.Sprintf("missing index entry %q for key %q", p.IndexKey, p.Key)
+	}
+	return fmt.Sprintf("stale index entry %q for key %q", p.IndexKey, p.Key)
+}
+
+// Verify checks that idx contains exactly the entries extracted from the
+// items in the source database and returns any inconsistencies found.  Verify
+// does not read either database into memory.
+func (idx *Index) Verify(txn *lmdb.Txn) ([]*Problem, error) {
+	var problems []*Problem
+
+	cur, err := txn.OpenCursor(idx.DBI)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close()
+
+	s := lmdbscan.New(txn, idx.Source)
+	defer s.Close()
+	for s.Scan() {
+		ikeys, err := idx.extract(s.Key(), s.Val())
+		if err != nil {
+			return nil, err
+		}
+		for _, ikey := range ikeys {
+			_, _, err := cur.Get(ikey, s.Key(), lmdb.GetBoth)
+			if lmdb.IsNotFound(err) {
+				problems = append(problems, &Problem{
+					IndexKey: ikey,
+					Key:      append([]byte(nil), s.Key()...),
+					Missing:  true,
+				})
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if s.Err() != nil {
+		return nil, s.Err()
+	}
+
+	is := lmdbscan.New(txn, idx.DBI)
+	defer is.Close()
+	for is.Scan() {
+		ok, err := idx.contains(txn, is.Key(), is.Val())
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			problems = append(problems, &Problem{
+				IndexKey: append([]byte(nil), is.Key()...),
+				Key:      append([]byte(nil), is.Val()...),
+			})
+		}
+	}
+	if is.Err() != nil {
+		return nil, is.Err()
+	}
+
+	return problems, nil
+}
+
+// contains returns true if the source database contains key and ikey is one
+// of the index keys of its item.
+func (idx *Index) contains(txn *lmdb.Txn, ikey, key []byte) (bool, error) {
+	val, err := txn.Get(idx.Source, key)
+	if lmdb.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	ikeys, err := idx.Extract(key, val)
+	if err != nil {
+		return false, err
+	}
+	for _, k := range ikeys {
+		if string(k) == string(ikey) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Set is a collection of indexes which are updated together.
+type Set struct {
+	indexes []*Index
+}
+
+// New returns a Set containing indexes.  A source database may have any
+// number of indexes.
+func New(indexes ...*Index) *Set {
+	return &Set{indexes: indexes}
+}
+
+// Indexes returns the indexes of the source database dbi.
+func (s *Set) Indexes(dbi lmdb.DBI) []*Index {
+	var indexes []*Index
+	for _, idx := range s.indexes {
+		if idx.Source == dbi {
+			indexes = append(indexes, idx)
+		}
+	}
+	return indexes
+}
+
+// Put stores an item in dbi, as with txn.Put, and updates the indexes of dbi
+// to reflect the item's new value.  The flags lmdb.Reserve and lmdb.Append
+// are not supported.
+func (s *Set) Put(txn *lmdb.Txn, dbi lmdb.DBI, key, val []byte, flags uint) error {
+	indexes := s.Indexes(dbi)
+	if len(indexes) == 0 {
+		return txn.Put(dbi, key, val, flags)
+	}
+
+	old, err := s.extractAll(txn, indexes, dbi, key)
+	if err != nil {
+		return err
+	}
+	err = txn.Put(dbi, key, val, flags)
+	if err != nil {
+		return err
+	}
+	for i, idx := range indexes {
+		err = idx.remove(txn, key, old[i])
+		if err != nil {
+			return err
+		}
+		ikeys, err := idx.extract(key, val)
+		if err != nil {
+			return err
+		}
+		err = idx.add(txn, key, ikeys)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Del deletes the item with key from dbi, as with txn.Del, and removes its
+// entries from the indexes of dbi.
+func (s *Set) Del(txn *lmdb.Txn, dbi lmdb.DBI, key []byte) error {
+	indexes := s.Indexes(dbi)
+	if len(indexes) == 0 {
+		return txn.Del(dbi, key, nil)
+	}
+
+	old, err := s.extractAll(txn, indexes, dbi, key)
+	if err != nil {
+		return err
+	}
+	err = txn.Del(dbi, key, nil)
+	if err != nil {
+		return err
+	}
+	for i, idx := range indexes {
+		err = idx.remove(txn, key, old[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractAll returns the index keys of the current item with key in dbi for
+// each of indexes.  If dbi does not contain key no index keys are returned.
+func (s *Set) extractAll(txn *lmdb.Txn, indexes []*Index, dbi lmdb.DBI, key []byte) ([][][]byte, error) {
+	ikeys := make([][][]byte, len(indexes))
+	val, err := txn.Get(dbi, key)
+	if lmdb.IsNotFound(err) {
+		return ikeys, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, idx := range indexes {
+		ikeys[i], err = idx.extract(key, val)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ikeys, nil
+}
+
+// Rebuild rebuilds every index in s.
+func (s *Set) Rebuild(txn *lmdb.Txn) error {
+	for _, idx := range s.indexes {
+		err := idx.Rebuild(txn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
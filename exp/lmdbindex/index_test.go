@@ -0,0 +1,175 @@
+package lmdbindex
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bmatsuo/lmdb-go/internal/lmdbtest"
+	"github.com/bmatsuo/lmdb-go/lmdb"
+)
+
+// tags indexes items whose values are comma separated lists of tags.
+func tags(k, v []byte) ([][]byte, error) {
+	if len(v) == 0 {
+		return nil, nil
+	}
+	return bytes.Split(v, []byte(",")), nil
+}
+
+func openIndex(t *testing.T) (*lmdb.Env, *Index) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := lmdbtest.OpenDBI(env, "items", lmdb.Create)
+	if err != nil {
+		lmdbtest.Destroy(env)
+		t.Fatal(err)
+	}
+	dbi, err := lmdbtest.OpenDBI(env, "tags", lmdb.Create|lmdb.DupSort)
+	if err != nil {
+		lmdbtest.Destroy(env)
+		t.Fatal(err)
+	}
+	return env, &Index{Source: src, DBI: dbi, Extract: tags}
+}
+
+func lookup(t *testing.T, env *lmdb.Env, idx *Index, ikey string) (keys []string) {
+	err := env.View(func(txn *lmdb.Txn) (err error) {
+		bkeys, err := idx.Get(txn, []byte(ikey))
+		for _, k := range bkeys {
+			keys = append(keys, string(k))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func verify(t *testing.T, env *lmdb.Env, idx *Index) (problems []string) {
+	err := env.View(func(txn *lmdb.Txn) (err error) {
+		p, err := idx.Verify(txn)
+		for _, p := range p {
+			problems = append(problems, p.String())
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return problems
+}
+
+func TestSet(t *testing.T) {
+	env, idx := openIndex(t)
+	defer lmdbtest.Destroy(env)
+	set := New(idx)
+
+	err := env.Update(func(txn *lmdb.Txn) (err error) {
+		for _, item := range [][2]string{
+			{"a", "red,blue"},
+			{"b", "blue"},
+			{"c", ""},
+			{"d", "green"},
+		} {
+			err = set.Put(txn, idx.Source, []byte(item[0]), []byte(item[1]), 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := lookup(t, env, idx, "blue"); !reflect.DeepEqual(keys, []string{"a", "b"}) {
+		t.Errorf("unexpected keys: %q", keys)
+	}
+
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		err = set.Put(txn, idx.Source, []byte("a"), []byte("red,green"), 0)
+		if err != nil {
+			return err
+		}
+		err = set.Put(txn, idx.Source, []byte("b"), []byte("x"), lmdb.NoOverwrite)
+		if !lmdb.IsErrno(err, lmdb.KeyExist) {
+			t.Errorf("unexpected error: %v (!= %v)", err, lmdb.KeyExist)
+		}
+		return set.Del(txn, idx.Source, []byte("d"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		ikey string
+		keys []string
+	}{
+		{"red", []string{"a"}},
+		{"blue", []string{"b"}},
+		{"green", []string{"a"}},
+		{"x", nil},
+	} {
+		keys := lookup(t, env, idx, test.ikey)
+		if !reflect.DeepEqual(keys, test.keys) {
+			t.Errorf("%s: unexpected keys: %q (!= %q)", test.ikey, keys, test.keys)
+		}
+	}
+	if problems := verify(t, env, idx); len(problems) != 0 {
+		t.Errorf("unexpected problems: %q", problems)
+	}
+
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		return set.Del(txn, idx.Source, []byte("d"))
+	})
+	if !lmdb.IsNotFound(err) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestIndex_Rebuild(t *testing.T) {
+	env, idx := openIndex(t)
+	defer lmdbtest.Destroy(env)
+
+	// Write the source directly so the index drifts.
+	err := lmdbtest.Put(env, idx.Source, lmdbtest.SimpleItemList{
+		{K: "a", V: "red"},
+		{K: "b", V: "red,blue"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = lmdbtest.Put(env, idx.DBI, lmdbtest.SimpleItemList{
+		{K: "blue", V: "a"},
+		{K: "red", V: "a"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	problems := verify(t, env, idx)
+	exp := []string{
+		`missing index entry "red" for key "b"`,
+		`missing index entry "blue" for key "b"`,
+		`stale index entry "blue" for key "a"`,
+	}
+	if !reflect.DeepEqual(problems, exp) {
+		t.Errorf("unexpected problems: %q (!= %q)", problems, exp)
+	}
+
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		return New(idx).Rebuild(txn)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if problems := verify(t, env, idx); len(problems) != 0 {
+		t.Errorf("unexpected problems: %q", problems)
+	}
+	if keys := lookup(t, env, idx, "red"); strings.Join(keys, ",") != "a,b" {
+		t.Errorf("unexpected keys: %q", keys)
+	}
+}
@@ -0,0 +1,506 @@
+/*
+Package lmdbqueue provides durable work queues stored in LMDB.
+
+Jobs are enqueued with increasing IDs taken from lmdb.Txn.NextSequence, which
+allows them to be written with lmdb.Append.  A dequeued job is leased to its
+consumer for a visibility timeout, during which it is not returned to other
+consumers.  The consumer acknowledges a job with Ack when it is finished, or
+returns it to the queue with Nack.  If a lease expires before the job is
+acknowledged the job becomes visible again.  Each time a job is dequeued its
+attempts are counted, and a job which is not acknowledged after
+Options.MaxAttempts attempts is moved to the queue's dead letters.
+
+A Queue stores its jobs in three named databases, so the environment must be
+opened with lmdb.Env.SetMaxDBs large enough for them and for the database
+named by lmdb.SequenceDB.
+
+DequeueWait blocks until a job is available.  Consumers are woken when a
+transaction which enqueues a job commits in the same process, using
+lmdb.Env.Observe, so they do not need to poll.  Jobs enqueued by other
+processes are only noticed if Options.Poll is set.
+*/
+package lmdbqueue
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bmatsuo/lmdb-go/lmdb"
+)
+
+var (
+	// ErrLeaseExpired is returned by Ack and Nack when the lease of a job
+	// has expired, in which case the job may have been dequeued again.
+	ErrLeaseExpired = errors.New("lmdbqueue: lease expired")
+
+	// ErrClosed is returned by DequeueWait when the Queue is closed.
+	ErrClosed = errors.New("lmdbqueue: queue closed")
+
+	// ErrCanceled is returned by DequeueWait when it is canceled.
+	ErrCanceled = errors.New("lmdbqueue: canceled")
+)
+
+var errCorrupted = errors.New("lmdbqueue: job record is truncated")
+
+// requeueBatch is the maximum number of expired leases returned to the queue
+// by each dequeue.
+const requeueBatch = 100
+
+// Options controls the behavior of a Queue.
+type Options struct {
+	// Visibility is the duration of the lease given to a consumer which
+	// dequeues a job.  If Visibility is zero 30 seconds is used.
+	Visibility time.Duration
+
+	// MaxAttempts is the number of times a job may be dequeued before it
+	// becomes a dead letter.  If MaxAttempts is zero jobs are retried
+	// indefinitely.
+	MaxAttempts int
+
+	// Poll is the interval at which DequeueWait checks the queue for jobs
+	// enqueued by other processes.  If Poll is zero DequeueWait only wakes
+	// for jobs enqueued in the same process.
+	Poll time.Duration
+
+	// Now returns the current time.  If Now is nil time.Now is used.
+	Now func() time.Time
+}
+
+// Job is a unit of work in a Queue.
+type Job struct {
+	ID       uint64
+	Attempts int       // Number of times the job has been dequeued
+	Payload  []byte    // Data given to Enqueue
+	Deadline time.Time // Expiration of the lease held on the job
+
+	lease []byte
+}
+
+// Stat describes the number of jobs in a Queue.
+type Stat struct {
+	Ready  uint64 // Jobs waiting to be dequeued
+	Leased uint64 // Jobs dequeued and not acknowledged
+	Dead   uint64 // Jobs which exceeded Options.MaxAttempts
+}
+
+// Queue is a durable work queue.
+type Queue struct {
+	env    *lmdb.Env
+	ready  lmdb.DBI
+	leased lmdb.DBI
+	dead   lmdb.DBI
+	opt    Options
+	obs    *lmdb.Observer
+
+	mut    sync.Mutex
+	wake   chan struct{} // closed when jobs are enqueued
+	done   chan struct{}
+	closed bool
+}
+
+// Open opens the queue called name in env, creating its databases if they
+// do not exist.  The Close method must be called when the Queue is no longer
+// needed.
+func Open(env *lmdb.Env, name string, opt *Options) (*Queue, error) {
+	q := &Queue{
+		env:  env,
+		wake: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	if opt != nil {
+		q.opt = *opt
+	}
+	if q.opt.Visibility <= 0 {
+		q.opt.Visibility = 30 * time.Second
+	}
+	err := env.Update(func(txn *lmdb.Txn) (err error) {
+		q.ready, err = txn.OpenDBI(name+".ready", lmdb.Create)
+		if err != nil {
+			return err
+		}
+		q.leased, err = txn.OpenDBI(name+".leased", lmdb.Create)
+		if err != nil {
+			return err
+		}
+		q.dead, err = txn.OpenDBI(name+".dead", lmdb.Create)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	q.obs = env.Observe(q.ready, nil, q.observe)
+	return q, nil
+}
+
+// Close stops observing the environment and wakes any goroutines blocked in
+// DequeueWait.
+func (q *Queue) Close() {
+	q.mut.Lock()
+	if q.closed {
+		q.mut.Unlock()
+		return
+	}
+	q.closed = true
+	close(q.done)
+	q.mut.Unlock()
+
+	// The observer must not be closed while holding q.mut, which is acquired
+	// by notifications in progress.
+	q.obs.Close()
+}
+
+// observe wakes consumers when jobs are stored in the ready database.
+func (q *Queue) observe(cs *lmdb.ChangeSet) {
+	for _, c := range cs.Changes {
+		if c.Op == lmdb.ChangePut {
+			q.mut.Lock()
+			close(q.wake)
+			q.wake = make(chan struct{})
+			q.mut.Unlock()
+			return
+		}
+	}
+}
+
+func (q *Queue) now() time.Time {
+	if q.opt.Now != nil {
+		return q.opt.Now()
+	}
+	return time.Now()
+}
+
+// A job record is the number of attempts followed by the payload.
+func record(attempts int, payload []byte) []byte {
+	rec := make([]byte, 4+len(payload))
+	binary.BigEndian.PutUint32(rec, uint32(attempts))
+	copy(rec[4:], payload)
+	return rec
+}
+
+func parseRecord(rec []byte) (attempts int, payload []byte, err error) {
+	if len(rec) < 4 {
+		return 0, nil, errCorrupted
+	}
+	return int(binary.BigEndian.Uint32(rec)), rec[4:], nil
+}
+
+func idKey(id uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, id)
+	return k
+}
+
+// leaseKey returns the key of a job in the leased database, which orders
+// leases by their deadlines.
+func leaseKey(deadline time.Time, id uint64) []byte {
+	k := make([]byte, 16)
+	binary.BigEndian.PutUint64(k, uint64(deadline.UnixNano()))
+	binary.BigEndian.PutUint64(k[8:], id)
+	return k
+}
+
+func parseLeaseKey(k []byte) (deadline time.Time, id uint64) {
+	deadline = time.Unix(0, int64(binary.BigEndian.Uint64(k)))
+	return deadline, binary.BigEndian.Uint64(k[8:])
+}
+
+// Enqueue adds a job with the given payload to the end of q and returns its
+// ID.  Consumers blocked in DequeueWait are woken when txn commits.  If a job
+// with the next ID already exists, because the sequence of q was moved
+// backwards, an error is returned for which lmdb.IsErrno(err, lmdb.KeyExist)
+// returns true.
+func (q *Queue) Enqueue(txn *lmdb.Txn, payload []byte) (uint64, error) {
+	id, err := txn.NextSequence(q.ready)
+	if err != nil {
+		return 0, err
+	}
+	// Sequence numbers reserved in batches by different processes may be
+	// out of order, in which case the job cannot be appended.  An existing
+	// job is never replaced, should the sequence have been moved backwards.
+	rec := record(0, payload)
+	err = txn.Put(q.ready, idKey(id), rec, lmdb.Append)
+	if lmdb.IsErrno(err, lmdb.KeyExist) {
+		err = txn.Put(q.ready, idKey(id), rec, lmdb.NoOverwrite)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// Dequeue leases the job at the front of q.  If q has no job ready an error
+// is returned for which lmdb.IsNotFound returns true.  Jobs whose leases have
+// expired are returned to the queue, or moved to its dead letters, before a
+// job is dequeued.
+func (q *Queue) Dequeue(txn *lmdb.Txn) (*Job, error) {
+	job, _, err := q.dequeue(txn)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, lmdb.NotFound
+	}
+	return job, nil
+}
+
+// dequeue leases the job at the front of q.  If no job is ready a nil Job is
+// returned along with the earliest deadline of a leased job, which is zero if
+// there are no leased jobs.
+func (q *Queue) dequeue(txn *lmdb.Txn) (*Job, time.Time, error) {
+	now := q.now()
+	err := q.requeue(txn, now)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	cur, err := txn.OpenCursor(q.ready)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer cur.Close()
+	k, rec, err := cur.Get(nil, nil, lmdb.First)
+	if lmdb.IsNotFound(err) {
+		next, err := q.nextDeadline(txn)
+		return nil, next, err
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	attempts, payload, err := parseRecord(rec)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	job := &Job{
+		ID:       binary.BigEndian.Uint64(k),
+		Attempts: attempts + 1,
+		Payload:  append([]byte(nil), payload...),
+		Deadline: now.Add(q.opt.Visibility),
+	}
+	job.lease = leaseKey(job.Deadline, job.ID)
+	err = cur.Del(0)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	err = txn.Put(q.leased, job.lease, record(job.Attempts, job.Payload), 0)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return job, time.Time{}, nil
+}
+
+// requeue returns jobs whose leases expired before now to the queue.
+func (q *Queue) requeue(txn *lmdb.Txn, now time.Time) error {
+	cur, err := txn.OpenCursor(q.leased)
+	if err != nil {
+		return err
+	}
+	defer cur.Close()
+
+	for i := 0; i < requeueBatch; i++ {
+		k, rec, err := cur.Get(nil, nil, lmdb.First)
+		if lmdb.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		deadline, id := parseLeaseKey(k)
+		if deadline.After(now) {
+			return nil
+		}
+		err = q.release(txn, id, rec)
+		if err != nil {
+			return err
+		}
+		err = cur.Del(0)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// release returns a job which was leased to the queue, or moves it to the
+// dead letters if it has no attempts remaining.
+func (q *Queue) release(txn *lmdb.Txn, id uint64, rec []byte) error {
+	attempts, _, err := parseRecord(rec)
+	if err != nil {
+		return err
+	}
+	dbi := q.ready
+	if q.opt.MaxAttempts > 0 && attempts >= q.opt.MaxAttempts {
+		dbi = q.dead
+	}
+	return txn.Put(dbi, idKey(id), rec, 0)
+}
+
+// nextDeadline returns the earliest deadline of a leased job, or the zero
+// Time if no job is leased.
+func (q *Queue) nextDeadline(txn *lmdb.Txn) (time.Time, error) {
+	cur, err := txn.OpenCursor(q.leased)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer cur.Close()
+	k, _, err := cur.Get(nil, nil, lmdb.First)
+	if lmdb.IsNotFound(err) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	deadline, _ := parseLeaseKey(k)
+	return deadline, nil
+}
+
+// DequeueWait leases the job at the front of q in its own update
+// transaction, waiting until a job is ready if necessary.  DequeueWait
+// returns ErrCanceled if cancel is closed, or ErrClosed if q is closed, while
+// it is waiting.  The cancel channel may be nil.
+func (q *Queue) DequeueWait(cancel <-chan struct{}) (*Job, error) {
+	for {
+		// The wake channel is read before the queue is checked so that an
+		// enqueue committed after the check is not missed.
+		q.mut.Lock()
+		wake, closed := q.wake, q.closed
+		q.mut.Unlock()
+		if closed {
+			return nil, ErrClosed
+		}
+
+		var job *Job
+		var next time.Time
+		err := q.env.Update(func(txn *lmdb.Txn) (err error) {
+			job, next, err = q.dequeue(txn)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if job != nil {
+			return job, nil
+		}
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		wait := q.opt.Poll
+		if !next.IsZero() {
+			d := next.Sub(q.now())
+			if d <= 0 {
+				continue
+			}
+			if wait <= 0 || d < wait {
+				wait = d
+			}
+		}
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			timeout = timer.C
+		}
+		select {
+		case <-wake:
+		case <-timeout:
+		case <-cancel:
+			err = ErrCanceled
+		case <-q.done:
+			err = ErrClosed
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Ack removes a leased job from q after it has been processed.  If the lease
+// on job has expired ErrLeaseExpired is returned, even if the job has not
+// been dequeued again, and the job is left to be reclaimed.
+func (q *Queue) Ack(txn *lmdb.Txn, job *Job) error {
+	if !job.Deadline.After(q.now()) {
+		return ErrLeaseExpired
+	}
+	err := txn.Del(q.leased, job.lease, nil)
+	if lmdb.IsNotFound(err) {
+		return ErrLeaseExpired
+	}
+	return err
+}
+
+// Nack returns a leased job to the front of q so that it may be dequeued
+// again, or moves it to the dead letters if it has no attempts remaining.
+// If the lease on job has expired ErrLeaseExpired is returned, as by Ack.
+func (q *Queue) Nack(txn *lmdb.Txn, job *Job) error {
+	if !job.Deadline.After(q.now()) {
+		return ErrLeaseExpired
+	}
+	rec, err := txn.Get(q.leased, job.lease)
+	if lmdb.IsNotFound(err) {
+		return ErrLeaseExpired
+	}
+	if err != nil {
+		return err
+	}
+	err = q.release(txn, job.ID, rec)
+	if err != nil {
+		return err
+	}
+	return txn.Del(q.leased, job.lease, nil)
+}
+
+// DeadLetters calls fn with each job which exceeded Options.MaxAttempts, in
+// order of ID.  If fn returns an error iteration stops and the error is
+// returned.  The Job passed to fn has no lease and must not be passed to Ack
+// or Nack.
+func (q *Queue) DeadLetters(txn *lmdb.Txn, fn func(job *Job) error) error {
+	cur, err := txn.OpenCursor(q.dead)
+	if err != nil {
+		return err
+	}
+	defer cur.Close()
+	for op := uint(lmdb.First); ; op = lmdb.Next {
+		k, rec, err := cur.Get(nil, nil, op)
+		if lmdb.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		attempts, payload, err := parseRecord(rec)
+		if err != nil {
+			return err
+		}
+		err = fn(&Job{
+			ID:       binary.BigEndian.Uint64(k),
+			Attempts: attempts,
+			Payload:  payload,
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Stat returns the number of jobs in each state.  Jobs with expired leases
+// are counted as leased until they are returned to the queue by a dequeue.
+func (q *Queue) Stat(txn *lmdb.Txn) (*Stat, error) {
+	stat := new(Stat)
+	for _, db := range []struct {
+		dbi lmdb.DBI
+		n   *uint64
+	}{
+		{q.ready, &stat.Ready},
+		{q.leased, &stat.Leased},
+		{q.dead, &stat.Dead},
+	} {
+		s, err := txn.Stat(db.dbi)
+		if err != nil {
+			return nil, err
+		}
+		*db.n = s.Entries
+	}
+	return stat, nil
+}
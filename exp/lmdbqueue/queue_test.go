@@ -0,0 +1,306 @@
+package lmdbqueue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bmatsuo/lmdb-go/internal/lmdbtest"
+	"github.com/bmatsuo/lmdb-go/lmdb"
+)
+
+func openQueue(t *testing.T, opt *Options) (*lmdb.Env, *Queue) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := Open(env, "jobs", opt)
+	if err != nil {
+		lmdbtest.Destroy(env)
+		t.Fatal(err)
+	}
+	return env, q
+}
+
+func enqueue(t *testing.T, env *lmdb.Env, q *Queue, payloads ...string) {
+	err := env.Update(func(txn *lmdb.Txn) (err error) {
+		for _, p := range payloads {
+			_, err = q.Enqueue(txn, []byte(p))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func dequeue(t *testing.T, env *lmdb.Env, q *Queue) (job *Job) {
+	err := env.Update(func(txn *lmdb.Txn) (err error) {
+		job, err = q.Dequeue(txn)
+		if lmdb.IsNotFound(err) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func stat(t *testing.T, env *lmdb.Env, q *Queue) (stat *Stat) {
+	err := env.View(func(txn *lmdb.Txn) (err error) {
+		stat, err = q.Stat(txn)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stat
+}
+
+func TestQueue(t *testing.T) {
+	env, q := openQueue(t, nil)
+	defer lmdbtest.Destroy(env)
+	defer q.Close()
+
+	enqueue(t, env, q, "a", "b", "c")
+
+	j1 := dequeue(t, env, q)
+	j2 := dequeue(t, env, q)
+	if j1 == nil || j2 == nil {
+		t.Fatalf("missing jobs: %v %v", j1, j2)
+	}
+	if string(j1.Payload) != "a" || j1.ID != 1 || j1.Attempts != 1 {
+		t.Errorf("unexpected job: %+v", j1)
+	}
+	if string(j2.Payload) != "b" || j2.ID != 2 {
+		t.Errorf("unexpected job: %+v", j2)
+	}
+	if s := stat(t, env, q); *s != (Stat{Ready: 1, Leased: 2}) {
+		t.Errorf("unexpected stat: %+v", s)
+	}
+
+	err := env.Update(func(txn *lmdb.Txn) (err error) {
+		err = q.Ack(txn, j1)
+		if err != nil {
+			return err
+		}
+		return q.Nack(txn, j2)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		err = q.Ack(txn, j1)
+		if err != ErrLeaseExpired {
+			t.Errorf("unexpected error: %v (!= %v)", err, ErrLeaseExpired)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The rejected job is returned to the front of the queue.
+	for _, exp := range []struct {
+		payload  string
+		attempts int
+	}{
+		{"b", 2},
+		{"c", 1},
+	} {
+		job := dequeue(t, env, q)
+		if job == nil {
+			t.Fatalf("missing job %q", exp.payload)
+		}
+		if string(job.Payload) != exp.payload || job.Attempts != exp.attempts {
+			t.Errorf("unexpected job: %+v", job)
+		}
+	}
+	if job := dequeue(t, env, q); job != nil {
+		t.Errorf("unexpected job: %+v", job)
+	}
+
+	enqueue(t, env, q, "d")
+	job := dequeue(t, env, q)
+	if job == nil || job.ID != 4 {
+		t.Errorf("unexpected job: %+v", job)
+	}
+}
+
+func TestQueue_Enqueue_exists(t *testing.T) {
+	env, q := openQueue(t, nil)
+	defer lmdbtest.Destroy(env)
+	defer q.Close()
+
+	enqueue(t, env, q, "a", "b")
+	err := env.Update(func(txn *lmdb.Txn) (err error) {
+		err = txn.SetSequence(q.ready, 0)
+		if err != nil {
+			return err
+		}
+		_, err = q.Enqueue(txn, []byte("c"))
+		return err
+	})
+	if !lmdb.IsErrno(err, lmdb.KeyExist) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	job := dequeue(t, env, q)
+	if job == nil || string(job.Payload) != "a" {
+		t.Errorf("unexpected job: %+v", job)
+	}
+}
+
+func TestQueue_visibility(t *testing.T) {
+	c := lmdbtest.NewClock(time.Unix(1000, 0))
+	env, q := openQueue(t, &Options{
+		Visibility:  time.Minute,
+		MaxAttempts: 2,
+		Now:         c.Now,
+	})
+	defer lmdbtest.Destroy(env)
+	defer q.Close()
+
+	enqueue(t, env, q, "a")
+	j1 := dequeue(t, env, q)
+	if j1 == nil {
+		t.Fatal("missing job")
+	}
+	if !j1.Deadline.Equal(time.Unix(1060, 0)) {
+		t.Errorf("unexpected deadline: %v", j1.Deadline)
+	}
+	if job := dequeue(t, env, q); job != nil {
+		t.Errorf("leased job was dequeued: %+v", job)
+	}
+
+	c.Advance(time.Minute)
+	j2 := dequeue(t, env, q)
+	if j2 == nil || j2.ID != j1.ID || j2.Attempts != 2 {
+		t.Fatalf("unexpected job: %+v", j2)
+	}
+	err := env.Update(func(txn *lmdb.Txn) (err error) {
+		return q.Ack(txn, j1)
+	})
+	if err != ErrLeaseExpired {
+		t.Errorf("unexpected error: %v (!= %v)", err, ErrLeaseExpired)
+	}
+
+	// The job has no attempts remaining when its second lease expires.
+	c.Advance(time.Minute)
+	if job := dequeue(t, env, q); job != nil {
+		t.Errorf("unexpected job: %+v", job)
+	}
+	if s := stat(t, env, q); *s != (Stat{Dead: 1}) {
+		t.Errorf("unexpected stat: %+v", s)
+	}
+	var dead []*Job
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		return q.DeadLetters(txn, func(job *Job) error {
+			dead = append(dead, job)
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dead) != 1 || string(dead[0].Payload) != "a" || dead[0].Attempts != 2 {
+		t.Errorf("unexpected dead letters: %+v", dead)
+	}
+}
+
+func TestQueue_leaseExpired(t *testing.T) {
+	c := lmdbtest.NewClock(time.Unix(1000, 0))
+	env, q := openQueue(t, &Options{
+		Visibility: time.Minute,
+		Now:        c.Now,
+	})
+	defer lmdbtest.Destroy(env)
+	defer q.Close()
+
+	enqueue(t, env, q, "a")
+	job := dequeue(t, env, q)
+	if job == nil {
+		t.Fatal("missing job")
+	}
+
+	// The lease expires before the job is reclaimed by another dequeue.
+	c.Advance(time.Minute)
+	err := env.Update(func(txn *lmdb.Txn) (err error) {
+		err = q.Ack(txn, job)
+		if err != ErrLeaseExpired {
+			t.Errorf("unexpected error: %v (!= %v)", err, ErrLeaseExpired)
+		}
+		err = q.Nack(txn, job)
+		if err != ErrLeaseExpired {
+			t.Errorf("unexpected error: %v (!= %v)", err, ErrLeaseExpired)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := stat(t, env, q); *s != (Stat{Leased: 1}) {
+		t.Errorf("unexpected stat: %+v", s)
+	}
+
+	redo := dequeue(t, env, q)
+	if redo == nil || redo.ID != job.ID || redo.Attempts != 2 {
+		t.Errorf("unexpected job: %+v", redo)
+	}
+}
+
+func TestQueue_DequeueWait(t *testing.T) {
+	env, q := openQueue(t, nil)
+	defer lmdbtest.Destroy(env)
+	defer q.Close()
+
+	jobs := make(chan *Job)
+	errs := make(chan error, 1)
+	go func() {
+		job, err := q.DequeueWait(nil)
+		if err != nil {
+			errs <- err
+			return
+		}
+		jobs <- job
+	}()
+
+	// Give the consumer time to block before enqueueing.
+	time.Sleep(10 * time.Millisecond)
+	enqueue(t, env, q, "a")
+	select {
+	case job := <-jobs:
+		if string(job.Payload) != "a" {
+			t.Errorf("unexpected job: %+v", job)
+		}
+	case err := <-errs:
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("consumer was not woken")
+	}
+
+	cancel := make(chan struct{})
+	close(cancel)
+	_, err := q.DequeueWait(cancel)
+	if err != ErrCanceled {
+		t.Errorf("unexpected error: %v (!= %v)", err, ErrCanceled)
+	}
+
+	go func() {
+		_, err := q.DequeueWait(nil)
+		errs <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	q.Close()
+	select {
+	case err := <-errs:
+		if err != ErrClosed {
+			t.Errorf("unexpected error: %v (!= %v)", err, ErrClosed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("consumer was not woken")
+	}
+}
@@ -0,0 +1,344 @@
+/*
+Package lmdbttl stores items in LMDB which expire after a deadline.
+
+A Store keeps its items in one database and indexes their deadlines in a
+second database, where the key of each entry is the item's deadline followed
+by its key.  Reads through a Store treat expired items as though they do not
+exist.  Expired items are removed from the databases by Sweep, or by a Sweeper
+which calls Sweep periodically.  Sweep deletes a bounded number of items in
+each transaction so that it never holds the writer lock for long.
+
+The value of each item is stored following an eight byte deadline, so the
+databases of a Store must not be written to except through the Store.  Keys
+may be at most eight bytes shorter than lmdb.Env.MaxKeySize.
+*/
+package lmdbttl
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bmatsuo/lmdb-go/lmdb"
+)
+
+var errCorrupted = errors.New("lmdbttl: item is missing its deadline")
+
+// Store holds items with deadlines.
+type Store struct {
+	DBI    lmdb.DBI // Database containing items
+	Expiry lmdb.DBI // Database containing the deadlines of items
+
+	// Now returns the current time.  If Now is nil time.Now is used.
+	Now func() time.Time
+}
+
+func (s *Store) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+// deadline encodes t as unix nanoseconds.  The zero Time, which never
+// expires, is encoded as zero.  Times before the epoch are encoded as one so
+// that they are expired.
+func deadline(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	n := t.UnixNano()
+	if n <= 0 {
+		return 1
+	}
+	return uint64(n)
+}
+
+func deadlineTime(d uint64) time.Time {
+	if d == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(d))
+}
+
+// expiryKey returns the key of the entry for key in the Expiry database.
+func expiryKey(d uint64, key []byte) []byte {
+	k := make([]byte, 8+len(key))
+	binary.BigEndian.PutUint64(k, d)
+	copy(k[8:], key)
+	return k
+}
+
+// get returns the deadline and value of key, whether or not it has expired.
+func (s *Store) get(txn *lmdb.Txn, key []byte) (uint64, []byte, error) {
+	v, err := txn.Get(s.DBI, key)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(v) < 8 {
+		return 0, nil, errCorrupted
+	}
+	return binary.BigEndian.Uint64(v), v[8:], nil
+}
+
+func (s *Store) expired(d uint64, now uint64) bool {
+	return d != 0 && d <= now
+}
+
+// Get returns the value of key.  If key does not exist or has expired an error
+// is returned for which lmdb.IsNotFound returns true.
+func (s *Store) Get(txn *lmdb.Txn, key []byte) ([]byte, error) {
+	d, v, err := s.get(txn, key)
+	if err != nil {
+		return nil, err
+	}
+	if s.expired(d, deadline(s.now())) {
+		return nil, lmdb.NotFound
+	}
+	return v, nil
+}
+
+// Expires returns the deadline of key, or the zero Time if key does not
+// expire.  If key does not exist or has expired an error is returned for
+// which lmdb.IsNotFound returns true.
+func (s *Store) Expires(txn *lmdb.Txn, key []byte) (time.Time, error) {
+	d, _, err := s.get(txn, key)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if s.expired(d, deadline(s.now())) {
+		return time.Time{}, lmdb.NotFound
+	}
+	return deadlineTime(d), nil
+}
+
+// Put stores an item which expires at the given time, replacing any existing
+// item with the same key.  If expires is the zero Time the item does not
+// expire.
+func (s *Store) Put(txn *lmdb.Txn, key, val []byte, expires time.Time) error {
+	err := s.unindex(txn, key)
+	if err != nil {
+		return err
+	}
+	d := deadline(expires)
+	buf := make([]byte, 8+len(val))
+	binary.BigEndian.PutUint64(buf, d)
+	copy(buf[8:], val)
+	err = txn.Put(s.DBI, key, buf, 0)
+	if err != nil {
+		return err
+	}
+	if d == 0 {
+		return nil
+	}
+	return txn.Put(s.Expiry, expiryKey(d, key), nil, 0)
+}
+
+// PutTTL stores an item which expires after ttl.
+func (s *Store) PutTTL(txn *lmdb.Txn, key, val []byte, ttl time.Duration) error {
+	return s.Put(txn, key, val, s.now().Add(ttl))
+}
+
+// Del deletes key.  If key does not exist an error is returned for which
+// lmdb.IsNotFound returns true.  Del deletes expired items which have not yet
+// been swept.
+func (s *Store) Del(txn *lmdb.Txn, key []byte) error {
+	err := s.unindex(txn, key)
+	if err != nil {
+		return err
+	}
+	return txn.Del(s.DBI, key, nil)
+}
+
+// unindex removes the deadline of the existing item with key, if any.
+func (s *Store) unindex(txn *lmdb.Txn, key []byte) error {
+	d, _, err := s.get(txn, key)
+	if lmdb.IsNotFound(err) || err == nil && d == 0 {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	err = txn.Del(s.Expiry, expiryKey(d, key), nil)
+	if lmdb.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// ForEach calls fn with each item which has not expired, in key order.  If fn
+// returns an error iteration stops and the error is returned.
+func (s *Store) ForEach(txn *lmdb.Txn, fn func(key, val []byte, expires time.Time) error) error {
+	cur, err := txn.OpenCursor(s.DBI)
+	if err != nil {
+		return err
+	}
+	defer cur.Close()
+
+	now := deadline(s.now())
+	for op := uint(lmdb.First); ; op = lmdb.Next {
+		k, v, err := cur.Get(nil, nil, op)
+		if lmdb.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(v) < 8 {
+			return errCorrupted
+		}
+		d := binary.BigEndian.Uint64(v)
+		if s.expired(d, now) {
+			continue
+		}
+		err = fn(k, v[8:], deadlineTime(d))
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Sweep deletes up to max expired items in a single update transaction and
+// returns the number of items deleted.  If max items are deleted more items
+// may remain to be swept.  If max is not positive there is no limit.
+func (s *Store) Sweep(env *lmdb.Env, max int) (n int, err error) {
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		n, err = s.sweep(txn, max)
+		return err
+	})
+	return n, err
+}
+
+func (s *Store) sweep(txn *lmdb.Txn, max int) (int, error) {
+	cur, err := txn.OpenCursor(s.Expiry)
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close()
+
+	now := deadline(s.now())
+	var n int
+	k, _, err := cur.Get(nil, nil, lmdb.First)
+	for err == nil && (max <= 0 || n < max) {
+		d := binary.BigEndian.Uint64(k)
+		if !s.expired(d, now) {
+			return n, nil
+		}
+		// The item is only deleted if the entry is its current deadline.
+		key := k[8:]
+		var dcur uint64
+		dcur, _, err = s.get(txn, key)
+		if err == nil && dcur == d {
+			err = txn.Del(s.DBI, key, nil)
+		}
+		if err != nil && !lmdb.IsNotFound(err) {
+			return n, err
+		}
+		err = cur.Del(0)
+		if err != nil {
+			return n, err
+		}
+		n++
+		// Following a deletion the cursor is positioned at the next entry,
+		// which Next returns without moving the cursor.
+		k, _, err = cur.Get(nil, nil, lmdb.Next)
+	}
+	if lmdb.IsNotFound(err) {
+		return n, nil
+	}
+	return n, err
+}
+
+// SweeperOptions controls the behavior of a Sweeper.
+type SweeperOptions struct {
+	// Interval is the time between sweeps.  If Interval is zero one second
+	// is used.
+	Interval time.Duration
+
+	// BatchSize is the maximum number of items deleted in each update
+	// transaction.  When a full batch is deleted another transaction is begun
+	// immediately.  If BatchSize is zero 1000 is used.
+	BatchSize int
+
+	// Error is called with any error encountered during a sweep.  If Error
+	// is nil errors are ignored and the sweep is retried after Interval.
+	Error func(err error)
+}
+
+// Sweeper removes expired items from a Store in the background.
+type Sweeper struct {
+	s    *Store
+	env  *lmdb.Env
+	opt  SweeperOptions
+	done chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+// StartSweeper starts a goroutine which sweeps expired items from s
+// periodically.  The Sweeper must be stopped with its Close method before env
+// is closed.
+func (s *Store) StartSweeper(env *lmdb.Env, opt *SweeperOptions) *Sweeper {
+	w := &Sweeper{
+		s:    s,
+		env:  env,
+		done: make(chan struct{}),
+	}
+	if opt != nil {
+		w.opt = *opt
+	}
+	if w.opt.Interval <= 0 {
+		w.opt.Interval = time.Second
+	}
+	if w.opt.BatchSize <= 0 {
+		w.opt.BatchSize = 1000
+	}
+	w.wg.Add(1)
+	go w.loop()
+	return w
+}
+
+// Close stops w and waits for any sweep in progress to finish.
+func (w *Sweeper) Close() {
+	w.once.Do(func() {
+		close(w.done)
+	})
+	w.wg.Wait()
+}
+
+func (w *Sweeper) loop() {
+	defer w.wg.Done()
+	ticker := time.NewTicker(w.opt.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			w.sweep()
+		}
+	}
+}
+
+// sweep deletes batches of expired items until a partial batch is deleted or
+// w is closed.
+func (w *Sweeper) sweep() {
+	for {
+		n, err := w.s.Sweep(w.env, w.opt.BatchSize)
+		if err != nil {
+			if w.opt.Error != nil {
+				w.opt.Error(err)
+			}
+			return
+		}
+		if n < w.opt.BatchSize {
+			return
+		}
+		select {
+		case <-w.done:
+			return
+		default:
+		}
+	}
+}
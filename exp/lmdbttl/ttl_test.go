@@ -0,0 +1,224 @@
+package lmdbttl
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bmatsuo/lmdb-go/internal/lmdbtest"
+	"github.com/bmatsuo/lmdb-go/lmdb"
+)
+
+func openStore(t *testing.T) (*lmdb.Env, *Store, *lmdbtest.Clock) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbi, err := lmdbtest.OpenDBI(env, "items", lmdb.Create)
+	if err != nil {
+		lmdbtest.Destroy(env)
+		t.Fatal(err)
+	}
+	exp, err := lmdbtest.OpenDBI(env, "expiry", lmdb.Create)
+	if err != nil {
+		lmdbtest.Destroy(env)
+		t.Fatal(err)
+	}
+	c := lmdbtest.NewClock(time.Unix(1000, 0))
+	return env, &Store{DBI: dbi, Expiry: exp, Now: c.Now}, c
+}
+
+func live(t *testing.T, env *lmdb.Env, s *Store) (keys []string) {
+	err := env.View(func(txn *lmdb.Txn) (err error) {
+		return s.ForEach(txn, func(k, v []byte, expires time.Time) error {
+			keys = append(keys, fmt.Sprintf("%s=%s", k, v))
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestStore(t *testing.T) {
+	env, s, c := openStore(t)
+	defer lmdbtest.Destroy(env)
+
+	err := env.Update(func(txn *lmdb.Txn) (err error) {
+		err = s.PutTTL(txn, []byte("a"), []byte("1"), time.Minute)
+		if err != nil {
+			return err
+		}
+		err = s.PutTTL(txn, []byte("b"), []byte("2"), time.Hour)
+		if err != nil {
+			return err
+		}
+		err = s.Put(txn, []byte("c"), []byte("3"), time.Time{})
+		if err != nil {
+			return err
+		}
+		// Replacing an item replaces its deadline.
+		return s.PutTTL(txn, []byte("b"), []byte("2"), time.Second)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Advance(2 * time.Second)
+	if keys := live(t, env, s); !reflect.DeepEqual(keys, []string{"a=1", "c=3"}) {
+		t.Errorf("unexpected items: %q", keys)
+	}
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		_, err = s.Get(txn, []byte("b"))
+		if !lmdb.IsNotFound(err) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		v, err := s.Get(txn, []byte("a"))
+		if err != nil {
+			return err
+		}
+		if string(v) != "1" {
+			t.Errorf("unexpected value: %q", v)
+		}
+		exp, err := s.Expires(txn, []byte("a"))
+		if err != nil {
+			return err
+		}
+		if !exp.Equal(time.Unix(1060, 0)) {
+			t.Errorf("unexpected deadline: %v", exp)
+		}
+		exp, err = s.Expires(txn, []byte("c"))
+		if err != nil {
+			return err
+		}
+		if !exp.IsZero() {
+			t.Errorf("unexpected deadline: %v", exp)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := s.Sweep(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("unexpected number swept: %d (!= 1)", n)
+	}
+
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		return s.Del(txn, []byte("a"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		// Only c remains, and it does not expire.
+		for _, test := range []struct {
+			dbi     lmdb.DBI
+			entries uint64
+		}{
+			{s.DBI, 1},
+			{s.Expiry, 0},
+		} {
+			stat, err := txn.Stat(test.dbi)
+			if err != nil {
+				return err
+			}
+			if stat.Entries != test.entries {
+				t.Errorf("dbi %d: unexpected entries: %d (!= %d)", test.dbi, stat.Entries, test.entries)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStore_Sweep(t *testing.T) {
+	env, s, c := openStore(t)
+	defer lmdbtest.Destroy(env)
+
+	err := env.Update(func(txn *lmdb.Txn) (err error) {
+		for i := 0; i < 25; i++ {
+			err = s.PutTTL(txn, []byte(fmt.Sprintf("k%02d", i)), []byte("v"), time.Duration(i)*time.Second)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Advance(20 * time.Second)
+	for _, exp := range []int{10, 10, 1, 0} {
+		n, err := s.Sweep(env, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != exp {
+			t.Errorf("unexpected number swept: %d (!= %d)", n, exp)
+		}
+	}
+	if keys := live(t, env, s); len(keys) != 4 {
+		t.Errorf("unexpected items: %q", keys)
+	}
+}
+
+func TestSweeper(t *testing.T) {
+	env, s, c := openStore(t)
+	defer lmdbtest.Destroy(env)
+
+	err := env.Update(func(txn *lmdb.Txn) (err error) {
+		for i := 0; i < 10; i++ {
+			err = s.PutTTL(txn, []byte(fmt.Sprint(i)), []byte("v"), time.Second)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Advance(time.Second)
+
+	w := s.StartSweeper(env, &SweeperOptions{
+		Interval:  time.Millisecond,
+		BatchSize: 3,
+		Error:     func(err error) { t.Error(err) },
+	})
+	defer w.Close()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		var entries uint64
+		err = env.View(func(txn *lmdb.Txn) (err error) {
+			stat, err := txn.Stat(s.DBI)
+			if err != nil {
+				return err
+			}
+			entries = stat.Entries
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entries == 0 {
+			break
+		}
+		select {
+		case <-timeout:
+			t.Fatalf("items were not swept: %d remain", entries)
+		case <-time.After(time.Millisecond):
+		}
+	}
+	w.Close()
+}
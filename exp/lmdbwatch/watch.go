@@ -0,0 +1,373 @@
+/*
+Package lmdbwatch watches an LMDB environment for changes made by any process
+sharing it.
+
+A Watcher polls the ID of the last transaction committed to the environment.
+When the ID advances the Watcher opens a view of the new snapshot and compares
+the watched key ranges against the snapshot viewed previously, emitting an
+Event for each key which was created, modified, or deleted.  Changes within a
+single process can be observed more efficiently using lmdb.Env.Observe.
+
+Because the previous snapshot is retained until the next change is detected
+a Watcher holds up to two slots in the reader lock table, and pages freed by
+writers cannot be reused while the Watcher's view of them remains open.
+Watched ranges should be kept small because every key in a watched range is
+compared each time the environment changes.
+
+Keys are compared lexicographically so ranges may only be watched in
+databases which use the default key comparison and do not have the DupSort
+flag.
+
+If the environment is resized by another process the Watcher cannot begin
+new transactions until the new size is adopted by calling lmdb.Env.SetMapSize
+with a size of zero.  LMDB requires that no transactions be active in the
+process during that call.  Write transactions do not appear in the reader
+lock table, so only the application can know when the call is safe.  By
+default the Watcher releases its snapshot and waits for the application to
+call SetMapSize.  If Options.ResizeMap is set the Watcher asks the
+application to run the resize on its behalf.  Either way the Watcher emits a
+Reset event, indicating that changes may have been missed, once it can view
+the environment again.
+
+When the reader lock table is full the Watcher retains its snapshot and
+retries later, so no changes are missed.
+*/
+package lmdbwatch
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/bmatsuo/lmdb-go/lmdb"
+	"github.com/bmatsuo/lmdb-go/lmdbscan"
+)
+
+// Range is a range of keys in a database.  The range includes keys greater
+// than or equal to Start and less than End.  A nil Start denotes the
+// beginning of the database and a nil End denotes its end.
+type Range struct {
+	DBI   lmdb.DBI
+	Start []byte
+	End   []byte
+}
+
+// Prefix returns a Range containing all keys in dbi which begin with prefix.
+func Prefix(dbi lmdb.DBI, prefix []byte) Range {
+	return Range{DBI: dbi, Start: prefix, End: lmdbscan.PrefixEnd(prefix)}
+}
+
+func (r *Range) contains(k []byte) bool {
+	return r.End == nil || bytes.Compare(k, r.End) < 0
+}
+
+// EventType identifies the kind of change described by an Event.
+type EventType int
+
+// The types of Event emitted by a Watcher.
+const (
+	Created  EventType = iota // A key was added.
+	Modified                  // The value of a key was changed.
+	Deleted                   // A key was removed.
+	Reset                     // Changes may have been missed.
+)
+
+// Event describes a change to a watched key.
+type Event struct {
+	Type  EventType
+	TxnID int64    // ID of the snapshot in which the change was observed
+	DBI   lmdb.DBI // Unused for Reset events
+	Key   []byte   // Unused for Reset events
+	Val   []byte   // The new value for Created and Modified events
+
+	// Err holds the error which caused a Reset event, if any.
+	Err error
+}
+
+// Options configures a Watcher.
+type Options struct {
+	// Interval is the time between polls of the environment.  The default
+	// interval is 100ms.
+	Interval time.Duration
+
+	// MaxBackoff limits the delay between attempts to view the environment
+	// after an error, which doubles after each consecutive failure starting
+	// from Interval.  The default limit is 5s.
+	MaxBackoff time.Duration
+
+	// Buffer is the capacity of the channel returned by Watcher.Events.
+	Buffer int
+
+	// ResizeMap is called when the environment has been resized by another
+	// process, after the Watcher has released its snapshot.  ResizeMap must
+	// call resize, which adopts the new size with lmdb.Env.SetMapSize, while
+	// no other transaction in the process is active, and return its error.
+	// The application is responsible for that exclusion.  ResizeMap is not
+	// called concurrently.
+	ResizeMap func(resize func() error) error
+}
+
+// Watcher emits events describing changes to watched key ranges.
+type Watcher struct {
+	env    *lmdb.Env
+	ranges []Range
+	opt    Options
+	events chan *Event
+	done   chan struct{}
+	wg     sync.WaitGroup
+	once   sync.Once
+
+	prev     *lmdb.Txn
+	prevID   int64
+	resetErr error // the cause of a pending Reset event
+}
+
+// New returns a Watcher for the given ranges of env, which begins watching
+// immediately.  Changes made before New is called are not reported.  If the
+// environment cannot be viewed when New is called a Reset event is emitted
+// once it can.  The Close method must be called when the Watcher is no longer
+// needed to release its transactions.
+func New(env *lmdb.Env, ranges []Range, opt *Options) *Watcher {
+	w := &Watcher{
+		env:    env,
+		ranges: ranges,
+		done:   make(chan struct{}),
+	}
+	if opt != nil {
+		w.opt = *opt
+	}
+	if w.opt.Interval <= 0 {
+		w.opt.Interval = 100 * time.Millisecond
+	}
+	if w.opt.MaxBackoff <= 0 {
+		w.opt.MaxBackoff = 5 * time.Second
+	}
+	w.events = make(chan *Event, w.opt.Buffer)
+
+	// The initial snapshot is taken synchronously so that any change
+	// committed after New returns is reported.
+	w.prev, w.prevID, _ = w.begin()
+	w.wg.Add(1)
+	go w.loop()
+	return w
+}
+
+// Events returns the channel on which events are emitted.  The channel is
+// closed when w is closed.
+func (w *Watcher) Events() <-chan *Event {
+	return w.events
+}
+
+// Close stops w and releases its transactions.  Close must not be called
+// while the environment is being closed.
+func (w *Watcher) Close() {
+	w.once.Do(func() {
+		close(w.done)
+		w.wg.Wait()
+	})
+}
+
+func (w *Watcher) loop() {
+	defer w.wg.Done()
+	defer close(w.events)
+	defer func() {
+		if w.prev != nil {
+			w.prev.Abort()
+		}
+	}()
+
+	delay := w.opt.Interval
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-timer.C:
+		}
+
+		err := w.poll()
+		if err == nil {
+			delay = w.opt.Interval
+		} else {
+			delay *= 2
+			if delay > w.opt.MaxBackoff {
+				delay = w.opt.MaxBackoff
+			}
+		}
+		timer.Reset(delay)
+	}
+}
+
+// begin begins a view of the current snapshot and returns its ID.
+func (w *Watcher) begin() (*lmdb.Txn, int64, error) {
+	txn, err := w.env.BeginTxn(nil, lmdb.Readonly)
+	if err != nil {
+		return nil, 0, err
+	}
+	txn.RawRead = true
+	return txn, int64(txn.ID()), nil
+}
+
+// poll checks env for a new snapshot and emits events for any changes to the
+// watched ranges.  An error is returned if the snapshot could not be viewed.
+func (w *Watcher) poll() error {
+	info, err := w.env.Info()
+	if err != nil {
+		return err
+	}
+	if w.prev != nil && info.LastTxnID == w.prevID {
+		return nil
+	}
+
+	next, id, err := w.begin()
+	if lmdb.IsMapResized(err) {
+		if w.prev != nil {
+			// The previous snapshot must be released before the map can
+			// be resized, which means changes will be missed.
+			w.prev.Abort()
+			w.prev = nil
+			w.resetErr = err
+		}
+		if w.opt.ResizeMap != nil && w.opt.ResizeMap(w.resizeMap) == nil {
+			next, id, err = w.begin()
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	prev := w.prev
+	w.prev, w.prevID = next, id
+	if prev == nil {
+		w.emit(&Event{Type: Reset, TxnID: id, Err: w.resetErr})
+		w.resetErr = nil
+		return nil
+	}
+	defer prev.Abort()
+	for i := range w.ranges {
+		err = w.diff(prev, next, &w.ranges[i])
+		if err != nil {
+			w.emit(&Event{Type: Reset, TxnID: id, Err: err})
+			return nil
+		}
+	}
+	return nil
+}
+
+// resizeMap adopts the map size of the environment.  It is passed to
+// Options.ResizeMap.
+func (w *Watcher) resizeMap() error {
+	return w.env.SetMapSize(0)
+}
+
+// diff emits an event for every key in r which differs between the
+// snapshots viewed by prev and next.
+func (w *Watcher) diff(prev, next *lmdb.Txn, r *Range) error {
+	pc, err := openRange(prev, r)
+	if err != nil {
+		return err
+	}
+	defer pc.cur.Close()
+	nc, err := openRange(next, r)
+	if err != nil {
+		return err
+	}
+	defer nc.cur.Close()
+
+	for pc.ok() || nc.ok() {
+		var c int
+		switch {
+		case !pc.ok():
+			c = 1
+		case !nc.ok():
+			c = -1
+		default:
+			c = bytes.Compare(pc.k, nc.k)
+		}
+		switch {
+		case c < 0:
+			w.emit(w.event(Deleted, r.DBI, pc.k, nil))
+			err = pc.next()
+		case c > 0:
+			w.emit(w.event(Created, r.DBI, nc.k, nc.v))
+			err = nc.next()
+		default:
+			if !bytes.Equal(pc.v, nc.v) {
+				w.emit(w.event(Modified, r.DBI, nc.k, nc.v))
+			}
+			err = pc.next()
+			if err == nil {
+				err = nc.next()
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Watcher) event(t EventType, dbi lmdb.DBI, k, v []byte) *Event {
+	e := &Event{
+		Type:  t,
+		TxnID: w.prevID,
+		DBI:   dbi,
+		Key:   append([]byte(nil), k...),
+	}
+	if v != nil {
+		e.Val = append([]byte(nil), v...)
+	}
+	return e
+}
+
+// emit sends e unless w is closed.
+func (w *Watcher) emit(e *Event) {
+	select {
+	case w.events <- e:
+	case <-w.done:
+	}
+}
+
+// rangeCursor iterates the keys of a Range.
+type rangeCursor struct {
+	r    *Range
+	cur  *lmdb.Cursor
+	k, v []byte
+	done bool
+}
+
+func openRange(txn *lmdb.Txn, r *Range) (*rangeCursor, error) {
+	cur, err := txn.OpenCursor(r.DBI)
+	if err != nil {
+		return nil, err
+	}
+	rc := &rangeCursor{r: r, cur: cur}
+	if r.Start == nil {
+		err = rc.get(nil, lmdb.First)
+	} else {
+		err = rc.get(r.Start, lmdb.SetRange)
+	}
+	if err != nil {
+		cur.Close()
+		return nil, err
+	}
+	return rc, nil
+}
+
+func (rc *rangeCursor) ok() bool {
+	return !rc.done
+}
+
+func (rc *rangeCursor) next() error {
+	return rc.get(nil, lmdb.Next)
+}
+
+func (rc *rangeCursor) get(setkey []byte, op uint) (err error) {
+	rc.k, rc.v, err = rc.cur.Get(setkey, nil, op)
+	if lmdb.IsNotFound(err) || err == nil && !rc.r.contains(rc.k) {
+		rc.done = true
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,230 @@
+package lmdbwatch
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bmatsuo/lmdb-go/internal/lmdbtest"
+	"github.com/bmatsuo/lmdb-go/lmdb"
+)
+
+func TestPrefix(t *testing.T) {
+	for _, test := range []struct {
+		prefix string
+		end    []byte
+	}{
+		{"", nil},
+		{"ab", []byte("ac")},
+		{"a\xff", []byte("b")},
+		{"\xff\xff", nil},
+	} {
+		r := Prefix(0, []byte(test.prefix))
+		if string(r.End) != string(test.end) || (r.End == nil) != (test.end == nil) {
+			t.Errorf("prefix %q: unexpected end %q (!= %q)", test.prefix, r.End, test.end)
+		}
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = lmdbtest.Put(env, dbi, lmdbtest.SimpleItemList{
+		{"a1", "v1"},
+		{"a2", "v2"},
+		{"b1", "v1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := New(env, []Range{Prefix(dbi, []byte("a"))}, &Options{Interval: time.Millisecond})
+	defer w.Close()
+
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		for _, item := range [][2]string{{"a0", "v0"}, {"a2", "v2x"}, {"b2", "v2"}} {
+			err = txn.Put(dbi, []byte(item[0]), []byte(item[1]), 0)
+			if err != nil {
+				return err
+			}
+		}
+		return txn.Del(dbi, []byte("a1"), nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []struct {
+		typ EventType
+		key string
+		val string
+	}{
+		{Created, "a0", "v0"},
+		{Deleted, "a1", ""},
+		{Modified, "a2", "v2x"},
+	}
+	for _, e := range exp {
+		select {
+		case ev := <-w.Events():
+			if ev.Type != e.typ || string(ev.Key) != e.key || string(ev.Val) != e.val {
+				t.Errorf("unexpected event: %d %q=%q (!= %d %q=%q)", ev.Type, ev.Key, ev.Val, e.typ, e.key, e.val)
+			}
+			if ev.DBI != dbi {
+				t.Errorf("unexpected dbi: %d (!= %d)", ev.DBI, dbi)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for event")
+		}
+	}
+
+	w.Close()
+	for ev := range w.Events() {
+		t.Errorf("unexpected event: %v", ev)
+	}
+}
+
+func TestWatcher_readersFull(t *testing.T) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxReaders: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := New(env, []Range{{DBI: dbi}}, &Options{
+		Interval:   time.Millisecond,
+		MaxBackoff: 10 * time.Millisecond,
+	})
+	defer w.Close()
+
+	// Occupy the remaining reader slot so the watcher cannot view the change
+	// until it is released.
+	txn, err := env.BeginTxn(nil, lmdb.Readonly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = lmdbtest.Put(env, dbi, lmdbtest.SimpleItemList{{"k", "v"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	txn.Abort()
+
+	select {
+	case ev := <-w.Events():
+		if ev.Type != Created || string(ev.Key) != "k" {
+			t.Errorf("unexpected event: %d %q", ev.Type, ev.Key)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timeout waiting for event")
+	}
+}
+
+func TestWatcher_mapResized(t *testing.T) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MapSize: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The test begins no transactions in env while the watcher runs, so
+	// the map may be resized whenever the watcher asks.
+	resized := make(chan struct{}, 1)
+	w := New(env, []Range{Prefix(dbi, []byte("k"))}, &Options{
+		Interval:   time.Millisecond,
+		MaxBackoff: 10 * time.Millisecond,
+		ResizeMap: func(resize func() error) error {
+			select {
+			case resized <- struct{}{}:
+			default:
+			}
+			return resize()
+		},
+	})
+	defer w.Close()
+
+	// A second Env stands in for another process which grows the map.
+	path, err := env.Path()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := lmdb.NewEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	err = other.SetMapSize(16 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = other.Open(path, 0, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = other.Update(func(txn *lmdb.Txn) (err error) {
+		root, err := txn.OpenRoot(0)
+		if err != nil {
+			return err
+		}
+		val := make([]byte, 4096)
+		for i := 0; i < 512; i++ {
+			err = txn.Put(root, []byte(fmt.Sprintf("big%03d", i)), val, 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case ev := <-w.Events():
+		if ev.Type != Reset || !lmdb.IsMapResized(ev.Err) {
+			t.Fatalf("unexpected event: %d %v", ev.Type, ev.Err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for reset")
+	}
+	select {
+	case <-resized:
+	default:
+		t.Errorf("map was not resized by the application")
+	}
+
+	err = other.Update(func(txn *lmdb.Txn) (err error) {
+		root, err := txn.OpenRoot(0)
+		if err != nil {
+			return err
+		}
+		return txn.Put(root, []byte("k"), []byte("v"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ev := <-w.Events():
+		if ev.Type != Created || string(ev.Key) != "k" {
+			t.Errorf("unexpected event: %d %q", ev.Type, ev.Key)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for event")
+	}
+}
@@ -1,8 +1,11 @@
 package lmdbtest
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/bmatsuo/lmdb-go/lmdb"
 )
@@ -168,3 +171,34 @@ func (i *SimpleItem) Item(j int) Item {
 	}
 	return i
 }
+
+// String returns the key and value of i quoted, for use in test failures.
+func (i *SimpleItem) String() string {
+	return fmt.Sprintf("%q=%q", i.K, i.V)
+}
+
+// Clock is a manually advanced time source for tests of packages which read
+// the current time through a function.
+type Clock struct {
+	mut sync.Mutex
+	t   time.Time
+}
+
+// NewClock returns a Clock reading t.
+func NewClock(t time.Time) *Clock {
+	return &Clock{t: t}
+}
+
+// Now returns the time of c.
+func (c *Clock) Now() time.Time {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.t
+}
+
+// Advance moves the time of c forward by d.
+func (c *Clock) Advance(d time.Duration) {
+	c.mut.Lock()
+	c.t = c.t.Add(d)
+	c.mut.Unlock()
+}
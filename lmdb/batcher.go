@@ -0,0 +1,207 @@
+package lmdb
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"time"
+)
+
+var errBatcherClosed = errors.New("batcher is closed")
+
+// Batcher coalesces write operations from many goroutines into shared update
+// transactions, amortizing the cost of beginning and committing (and
+// syncing) a transaction over all the operations in a batch.  Transactions
+// are executed by a dedicated goroutine which is locked to its thread, so
+// Batch may be called from any goroutine.
+//
+// Each operation in a batch is executed in its own subtransaction (see
+// Txn.Sub).  If an operation returns an error its changes are rolled back
+// without affecting the rest of the batch and, after the batch has been
+// committed, the operation is retried alone in a new transaction.  Because of
+// this an operation may be called more than once and must be idempotent, with
+// any side effects outside of the transaction deferred until Batch returns.
+//
+// Beginning and committing a subtransaction has a cost of its own, so a
+// Batcher is only beneficial when the cost of committing a transaction, which
+// is typically dominated by syncing to disk, is high in comparison.
+//
+// Subtransactions cannot be used in an environment opened with the WriteMap
+// flag.  In such an environment every operation will fail within its batch
+// and be retried alone, eliminating any benefit from batching.
+type Batcher struct {
+	env      *Env
+	maxSize  int
+	maxDelay time.Duration
+	ops      chan *batchOp
+	done     chan struct{}
+	mut      sync.RWMutex
+	closed   bool
+}
+
+// batchOp is an operation submitted to a Batcher and its result.
+type batchOp struct {
+	fn       TxnOp
+	err      error
+	panicked bool
+	panicv   interface{}
+	done     chan struct{}
+}
+
+// NewBatcher returns a Batcher which executes operations in env.  A batch is
+// committed once it holds maxSize operations or maxDelay has elapsed since
+// its first operation was received, whichever comes first.  If maxDelay is
+// not positive a batch only contains the operations which are waiting when it
+// begins.  NewBatcher panics if maxSize is not positive.
+//
+// The Close method must be called when the Batcher is no longer needed to
+// stop its goroutine.
+func NewBatcher(env *Env, maxSize int, maxDelay time.Duration) *Batcher {
+	if maxSize <= 0 {
+		panic("batch size must be positive")
+	}
+	b := &Batcher{
+		env:      env,
+		maxSize:  maxSize,
+		maxDelay: maxDelay,
+		ops:      make(chan *batchOp),
+		done:     make(chan struct{}),
+	}
+	go b.loop()
+	return b
+}
+
+// Batch executes fn in a write transaction shared with other operations and
+// returns after the transaction has been committed.  Batch returns the error
+// returned by fn, or an error encountered committing the transaction.  If fn
+// panics the panic is propagated to the caller of Batch.
+//
+// As with Update, fn must not retain the Txn it is passed or use it from
+// other goroutines.  Any call to Commit, Abort, Reset, or Renew on the Txn
+// will panic.
+func (b *Batcher) Batch(fn TxnOp) error {
+	op := &batchOp{
+		fn:   fn,
+		done: make(chan struct{}),
+	}
+
+	b.mut.RLock()
+	if b.closed {
+		b.mut.RUnlock()
+		return errBatcherClosed
+	}
+	b.ops <- op
+	b.mut.RUnlock()
+
+	<-op.done
+	if op.panicked {
+		panic(op.panicv)
+	}
+	return op.err
+}
+
+// Close waits for pending operations to complete and stops the goroutine
+// executing transactions for b.  Calls to Batch after Close return an error.
+func (b *Batcher) Close() {
+	b.mut.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.ops)
+	}
+	b.mut.Unlock()
+	<-b.done
+}
+
+func (b *Batcher) loop() {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer close(b.done)
+
+	for op := range b.ops {
+		b.run(b.collect(op))
+	}
+}
+
+// collect returns a batch beginning with op and containing any operations
+// received before the batch is full or its delay has elapsed.
+func (b *Batcher) collect(op *batchOp) []*batchOp {
+	batch := []*batchOp{op}
+	if b.maxDelay <= 0 {
+		for len(batch) < b.maxSize {
+			select {
+			case op, ok := <-b.ops:
+				if !ok {
+					return batch
+				}
+				batch = append(batch, op)
+			default:
+				return batch
+			}
+		}
+		return batch
+	}
+
+	timer := time.NewTimer(b.maxDelay)
+	defer timer.Stop()
+	for len(batch) < b.maxSize {
+		select {
+		case op, ok := <-b.ops:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, op)
+		case <-timer.C:
+			return batch
+		}
+	}
+	return batch
+}
+
+// run executes batch in a single transaction and retries failed operations
+// individually.  Every operation in batch is complete when run returns.
+func (b *Batcher) run(batch []*batchOp) {
+	var failed []*batchOp
+	err := b.env.UpdateLocked(func(txn *Txn) (err error) {
+		for _, op := range batch {
+			err = op.call(txn.Sub)
+			if err != nil && !op.panicked {
+				failed = append(failed, op)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		// The batch could not be committed so no operation succeeded.
+		for _, op := range batch {
+			if !op.panicked {
+				op.err = err
+			}
+			close(op.done)
+		}
+		return
+	}
+
+	for _, op := range batch {
+		if op.err == nil || op.panicked {
+			close(op.done)
+		}
+	}
+	for _, op := range failed {
+		op.err = op.call(b.env.UpdateLocked)
+		close(op.done)
+	}
+}
+
+// call executes op.fn using run and stores its result in op.err.  A panic in
+// op.fn is recovered and stored in op so that it can be propagated to the
+// goroutine which called Batch.
+func (op *batchOp) call(run func(TxnOp) error) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			op.panicked = true
+			op.panicv = e
+		}
+		op.err = err
+	}()
+	return run(op.fn)
+}
@@ -0,0 +1,151 @@
+package lmdb
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBatcher(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openDBI(env, "testdb", Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewBatcher(env, 10, 10*time.Millisecond)
+	defer b.Close()
+
+	const n = 50
+	errBad := errors.New("bad op")
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = b.Batch(func(txn *Txn) (err error) {
+				k := []byte(fmt.Sprintf("k%02d", i))
+				err = txn.Put(dbi, k, k, 0)
+				if err != nil {
+					return err
+				}
+				if i%10 == 3 {
+					return errBad
+				}
+				return nil
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if i%10 == 3 {
+			if err != errBad {
+				t.Errorf("op %d: unexpected error: %v (!= %v)", i, err, errBad)
+			}
+		} else if err != nil {
+			t.Errorf("op %d: %v", i, err)
+		}
+	}
+
+	err = env.View(func(txn *Txn) (err error) {
+		for i := 0; i < n; i++ {
+			k := []byte(fmt.Sprintf("k%02d", i))
+			_, err = txn.Get(dbi, k)
+			if i%10 == 3 {
+				if !IsNotFound(err) {
+					t.Errorf("key %q: expected NotFound: %v", k, err)
+				}
+			} else if err != nil {
+				t.Errorf("key %q: %v", k, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBatcher_retry(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openDBI(env, "testdb", Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewBatcher(env, 10, 0)
+	defer b.Close()
+
+	// the op fails within its batch and succeeds when it is retried alone.
+	var calls int32
+	err = b.Batch(func(txn *Txn) (err error) {
+		err = txn.Put(dbi, []byte("k"), []byte("v"), 0)
+		if err != nil {
+			return err
+		}
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return fmt.Errorf("transient error")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if calls != 2 {
+		t.Errorf("unexpected number of calls: %d (!= 2)", calls)
+	}
+
+	err = env.View(func(txn *Txn) (err error) {
+		_, err = txn.Get(dbi, []byte("k"))
+		return err
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBatcher_panic(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	b := NewBatcher(env, 10, 0)
+	defer b.Close()
+
+	func() {
+		defer func() {
+			if e := recover(); e != "boom" {
+				t.Errorf("unexpected panic: %v", e)
+			}
+		}()
+		b.Batch(func(txn *Txn) error { panic("boom") })
+		t.Errorf("expected a panic")
+	}()
+
+	err := b.Batch(func(txn *Txn) error { return nil })
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBatcher_Close(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	b := NewBatcher(env, 10, 0)
+	b.Close()
+	b.Close()
+
+	err := b.Batch(func(txn *Txn) error { return nil })
+	if err != errBatcherClosed {
+		t.Errorf("unexpected error: %v (!= %v)", err, errBatcherClosed)
+	}
+}
@@ -1,11 +1,14 @@
 package lmdb
 
 import (
+	"bytes"
 	crand "crypto/rand"
 	"encoding/binary"
 	"math/rand"
+	"sort"
 	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func BenchmarkEnv_ReaderList(b *testing.B) {
@@ -60,6 +63,62 @@ func (r *readerList) Next(ln string) error {
 }
 
 // repeatedly put (overwrite) keys.
+// BenchmarkEnv_Update_parallel writes a single key in each of many concurrent
+// update transactions.
+func BenchmarkEnv_Update_parallel(b *testing.B) {
+	env := setup(b)
+	defer clean(env, b)
+
+	dbi, err := openDBI(env, "benchmark", Create)
+	bMust(b, err, "opening database")
+
+	var n uint64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var k [8]byte
+		for pb.Next() {
+			binary.BigEndian.PutUint64(k[:], atomic.AddUint64(&n, 1))
+			err := env.Update(func(txn *Txn) (err error) {
+				return txn.Put(dbi, k[:], k[:], 0)
+			})
+			if err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+// BenchmarkBatcher_Batch_parallel is comparable to
+// BenchmarkEnv_Update_parallel but coalesces writes using a Batcher.
+func BenchmarkBatcher_Batch_parallel(b *testing.B) {
+	env := setup(b)
+	defer clean(env, b)
+
+	dbi, err := openDBI(env, "benchmark", Create)
+	bMust(b, err, "opening database")
+
+	batcher := NewBatcher(env, 100, time.Millisecond)
+	defer batcher.Close()
+
+	var n uint64
+	b.SetParallelism(16)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var k [8]byte
+		for pb.Next() {
+			binary.BigEndian.PutUint64(k[:], atomic.AddUint64(&n, 1))
+			err := batcher.Batch(func(txn *Txn) (err error) {
+				return txn.Put(dbi, k[:], k[:], 0)
+			})
+			if err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
 func BenchmarkTxn_Put(b *testing.B) {
 	initRandSource(b)
 	env := setup(b)
@@ -271,6 +330,262 @@ func BenchmarkTxn_Get_raw_ro(b *testing.B) {
 	}
 }
 
+// repeatedly get random keys from an IntegerKey database using GetUint64.
+// Compare with BenchmarkTxn_Get_bigEndianKey_raw_ro.
+func BenchmarkTxn_Get_integerKey_raw_ro(b *testing.B) {
+	env := setup(b)
+	defer clean(env, b)
+
+	dbi := populateIntegerBenchDB(b, env, true)
+
+	err := env.View(func(txn *Txn) (err error) {
+		txn.RawRead = true
+		b.ResetTimer()
+		defer b.StopTimer()
+		for i := 0; i < b.N; i++ {
+			_, err := txn.GetUint64(dbi, uint64(rand.Intn(benchDBNumKeys)))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Error(err)
+	}
+}
+
+// repeatedly get random big-endian []byte keys from a database.  Compare with
+// BenchmarkTxn_Get_integerKey_raw_ro.
+func BenchmarkTxn_Get_bigEndianKey_raw_ro(b *testing.B) {
+	env := setup(b)
+	defer clean(env, b)
+
+	dbi := populateIntegerBenchDB(b, env, false)
+
+	err := env.View(func(txn *Txn) (err error) {
+		txn.RawRead = true
+		b.ResetTimer()
+		defer b.StopTimer()
+		var k [8]byte
+		for i := 0; i < b.N; i++ {
+			binary.BigEndian.PutUint64(k[:], uint64(rand.Intn(benchDBNumKeys)))
+			_, err := txn.Get(dbi, k[:])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Error(err)
+	}
+}
+
+// repeatedly append keys to an IntegerKey database using PutUint64.  Compare
+// with BenchmarkTxn_Put_bigEndianKey_append.
+func BenchmarkTxn_Put_integerKey_append(b *testing.B) {
+	env := setup(b)
+	defer clean(env, b)
+
+	bMust(b, env.SetMapSize(2<<30), "setting map size")
+	dbi, err := openDBI(env, "benchmark", Create|IntegerKey)
+	bMust(b, err, "opening database")
+
+	val := make([]byte, 8)
+	err = env.Update(func(txn *Txn) (err error) {
+		b.ResetTimer()
+		defer b.StopTimer()
+		for i := 0; i < b.N; i++ {
+			err = txn.PutUint64(dbi, uint64(i), val, Append)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Error(err)
+	}
+}
+
+// repeatedly append big-endian []byte keys to a database.  Compare with
+// BenchmarkTxn_Put_integerKey_append.
+func BenchmarkTxn_Put_bigEndianKey_append(b *testing.B) {
+	env := setup(b)
+	defer clean(env, b)
+
+	bMust(b, env.SetMapSize(2<<30), "setting map size")
+	dbi, err := openDBI(env, "benchmark", Create)
+	bMust(b, err, "opening database")
+
+	val := make([]byte, 8)
+	err = env.Update(func(txn *Txn) (err error) {
+		b.ResetTimer()
+		defer b.StopTimer()
+		var k [8]byte
+		for i := 0; i < b.N; i++ {
+			binary.BigEndian.PutUint64(k[:], uint64(i))
+			err = txn.Put(dbi, k[:], val, Append)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Error(err)
+	}
+}
+
+// BenchmarkTxn_PutBatch_bigEndianKey_append is comparable to
+// BenchmarkTxn_Put_bigEndianKey_append but stores items in batches of 100.
+func BenchmarkTxn_PutBatch_bigEndianKey_append(b *testing.B) {
+	env := setup(b)
+	defer clean(env, b)
+
+	bMust(b, env.SetMapSize(2<<30), "setting map size")
+	dbi, err := openDBI(env, "benchmark", Create)
+	bMust(b, err, "opening database")
+
+	val := make([]byte, 8)
+	buf := new(PutBuffer)
+	err = env.Update(func(txn *Txn) (err error) {
+		b.ResetTimer()
+		defer b.StopTimer()
+		var k [8]byte
+		for i := 0; i < b.N; i++ {
+			binary.BigEndian.PutUint64(k[:], uint64(i))
+			buf.Add(k[:], val)
+			if buf.Len() == 100 || i == b.N-1 {
+				_, err = txn.PutBatch(dbi, buf, Append)
+				if err != nil {
+					return err
+				}
+				buf.Reset()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Error(err)
+	}
+}
+
+// repeatedly scan an IntegerKey database using GetUint64.  Compare with
+// BenchmarkCursor_Scan_bigEndianKey_raw_ro.
+func BenchmarkCursor_Scan_integerKey_raw_ro(b *testing.B) {
+	env := setup(b)
+	defer clean(env, b)
+
+	dbi := populateIntegerBenchDB(b, env, true)
+
+	err := env.View(func(txn *Txn) (err error) {
+		txn.RawRead = true
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+
+		b.ResetTimer()
+		defer b.StopTimer()
+		var sum uint64
+		for i := 0; i < b.N; i++ {
+			k, _, err := cur.GetUint64(0, Next)
+			if IsNotFound(err) {
+				// rewind so that every iteration reads an item.
+				k, _, err = cur.GetUint64(0, First)
+			}
+			if err != nil {
+				return err
+			}
+			sum += k
+		}
+		benchKeySum = sum
+		return nil
+	})
+	if err != nil {
+		b.Error(err)
+	}
+}
+
+// repeatedly scan a database with big-endian []byte keys and decode them.
+// Compare with BenchmarkCursor_Scan_integerKey_raw_ro.
+func BenchmarkCursor_Scan_bigEndianKey_raw_ro(b *testing.B) {
+	env := setup(b)
+	defer clean(env, b)
+
+	dbi := populateIntegerBenchDB(b, env, false)
+
+	err := env.View(func(txn *Txn) (err error) {
+		txn.RawRead = true
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+
+		b.ResetTimer()
+		defer b.StopTimer()
+		var sum uint64
+		for i := 0; i < b.N; i++ {
+			k, _, err := cur.Get(nil, nil, Next)
+			if IsNotFound(err) {
+				// rewind so that every iteration reads an item.
+				k, _, err = cur.Get(nil, nil, First)
+			}
+			if err != nil {
+				return err
+			}
+			sum += binary.BigEndian.Uint64(k)
+		}
+		benchKeySum = sum
+		return nil
+	})
+	if err != nil {
+		b.Error(err)
+	}
+}
+
+// benchKeySum holds the sum of the keys read by the scan benchmarks so that
+// decoding the keys is not optimized away.
+var benchKeySum uint64
+
+// populateIntegerBenchDB writes benchDBNumKeys sequential 8-byte keys to a
+// new database.  If integer is true the database is opened with the
+// IntegerKey flag and keys are native integers, otherwise keys are encoded in
+// big-endian byte order.
+func populateIntegerBenchDB(b *testing.B, env *Env, integer bool) DBI {
+	var dbi DBI
+	err := env.Update(func(txn *Txn) (err error) {
+		if integer {
+			dbi, err = txn.OpenDBI("benchmark", Create|IntegerKey)
+		} else {
+			dbi, err = txn.OpenDBI("benchmark", Create)
+		}
+		if err != nil {
+			return err
+		}
+		val := make([]byte, 64)
+		var k [8]byte
+		for i := 0; i < benchDBNumKeys; i++ {
+			if integer {
+				err = txn.PutUint64(dbi, uint64(i), val, Append)
+			} else {
+				binary.BigEndian.PutUint64(k[:], uint64(i))
+				err = txn.Put(dbi, k[:], val, Append)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	bMust(b, err, "populating database")
+	return dbi
+}
+
 func BenchmarkGet_1_alloc_rw_copy(b *testing.B) {
 	env := setup(b)
 	defer clean(env, b)
@@ -748,6 +1063,81 @@ func BenchmarkGet_25_renew_ro_raw(b *testing.B) {
 	}
 }
 
+func BenchmarkGetMany_25_renew_ro_raw(b *testing.B) {
+	benchmarkGetMany(b, 25, false)
+}
+
+func BenchmarkCursor_GetMany_25_renew_ro_raw(b *testing.B) {
+	benchmarkGetMany(b, 25, true)
+}
+
+// benchmarkGetMany is comparable to the BenchmarkGet_*_renew_ro_raw
+// benchmarks but retrieves each batch with a single call to GetMany.  If cur
+// is true the keys in each batch are sorted and retrieved using a cursor.
+func benchmarkGetMany(b *testing.B, batch int, cur bool) {
+	env := setup(b)
+	defer clean(env, b)
+
+	dbi := openBenchDBI(b, env)
+
+	recordSet := testRecordSetSized(benchmarkScanDBSize)
+	if !populateDBI(b, env, dbi, recordSet) {
+		return
+	}
+
+	txn, err := env.BeginTxn(nil, Readonly)
+	if err != nil {
+		b.Error(err)
+		return
+	}
+	defer txn.Abort()
+	txn.RawRead = true
+	txn.Reset()
+
+	keys := make([][]byte, batch)
+	res := new(GetManyResult)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for j := range keys {
+			keys[j] = benchmarkGetKey(i, j, batch, recordSet.Len())
+		}
+		if cur {
+			sort.Sort(byteSlices(keys))
+		}
+
+		err = txn.Renew()
+		if err != nil {
+			b.Error(err)
+			return
+		}
+
+		if cur {
+			var c *Cursor
+			c, err = txn.OpenCursor(dbi)
+			if err == nil {
+				err = c.GetMany(keys, res)
+				c.Close()
+			}
+		} else {
+			err = txn.GetMany(dbi, keys, res)
+		}
+		if err != nil {
+			b.Error(err)
+			return
+		}
+
+		txn.Reset()
+	}
+}
+
+type byteSlices [][]byte
+
+func (s byteSlices) Len() int           { return len(s) }
+func (s byteSlices) Less(i, j int) bool { return bytes.Compare(s[i], s[j]) < 0 }
+func (s byteSlices) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
 func benchmarkGetBatch(txn *Txn, dbi DBI, i, batch, n int) error {
 	for j := 0; j < batch; j++ {
 		_, err := txn.Get(dbi, benchmarkGetKey(i, j, batch, n))
@@ -1580,6 +1970,66 @@ func populateBenchmarkDB(env *Env, dbi DBI, rc *randSourceCursor) ([][]byte, err
 	return ps, nil
 }
 
+func BenchmarkScan_10000_batch_renew_ro_raw(b *testing.B) {
+	env := setup(b)
+	defer clean(env, b)
+
+	dbi := openBenchDBI(b, env)
+
+	if !populateDBI(b, env, dbi, testRecordSetSized(benchmarkScanDBSize)) {
+		return
+	}
+
+	txn, err := env.BeginTxn(nil, Readonly)
+	if err != nil {
+		b.Error(err)
+		return
+	}
+	defer txn.Abort()
+
+	cur, err := txn.OpenCursor(dbi)
+	if err != nil {
+		b.Error(err)
+		return
+	}
+	defer cur.Close()
+
+	txn.RawRead = true
+
+	txn.Reset()
+
+	batch := NewRecordBatch(100)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		err = txn.Renew()
+		if err != nil {
+			b.Error(err)
+			return
+		}
+
+		err = cur.Renew(txn)
+		if err != nil {
+			b.Error(err)
+			return
+		}
+
+		for n := 0; n < 10000; n += batch.Len() {
+			err = cur.GetBatch(Next, batch)
+			if IsNotFound(err) {
+				break
+			}
+			if err != nil {
+				b.Error(err)
+				return
+			}
+		}
+
+		txn.Reset()
+	}
+}
+
 func benchmarkScanDBI(cur *Cursor, dbi DBI, n int) error {
 	for i := 0; n < 0 || i < n; i++ {
 		_, _, err := cur.Get(nil, nil, Next)
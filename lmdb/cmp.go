@@ -0,0 +1,159 @@
+package lmdb
+
+/*
+#include "lmdb.h"
+#include "lmdbgo.h"
+*/
+import "C"
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// CmpFunc is a comparison function that determines the order of keys in a
+// database (or the order of duplicate values in a DupSort database).  A
+// CmpFunc is either one of the builtin functions implemented in C or a Go
+// function registered with RegisterCmpFunc.
+//
+// See MDB_cmp_func.
+type CmpFunc struct {
+	fn *C.MDB_cmp_func
+}
+
+// Builtin comparison functions implemented in C.  The builtin functions do
+// not incur the overhead of calling into Go from C and should be preferred
+// over registered Go functions when they fit the data.
+var (
+	// CmpInt64 orders 8-byte keys as signed integers stored in the native
+	// byte order of the host, the same representation used by IntegerKey.
+	// Keys of other lengths sort before or after all 8-byte keys according
+	// to their length.
+	CmpInt64 = &CmpFunc{C.lmdbgo_cmp_builtin(C.LMDBGO_CMP_INT64)}
+
+	// CmpFloat64 orders 8-byte keys as IEEE 754 double precision floating
+	// point numbers stored in the native byte order of the host.  NaN values
+	// sort after all other values.  Keys of other lengths are treated as
+	// they are by CmpInt64.
+	CmpFloat64 = &CmpFunc{C.lmdbgo_cmp_builtin(C.LMDBGO_CMP_FLOAT64)}
+
+	// CmpReverse orders keys in descending lexicographic order.  It is not
+	// the same as the ReverseKey flag which compares keys starting from
+	// their final byte.
+	CmpReverse = &CmpFunc{C.lmdbgo_cmp_builtin(C.LMDBGO_CMP_REVERSE)}
+
+	// CmpLengthPrefixed orders composite keys made of segments which are
+	// each prefixed with their length encoded as a uvarint (see the function
+	// binary.PutUvarint).  Keys are compared segment by segment so that a
+	// segment sorts before any longer segment it is a prefix of, regardless
+	// of the segments which follow.  Malformed trailing bytes are compared
+	// lexicographically.
+	CmpLengthPrefixed = &CmpFunc{C.lmdbgo_cmp_builtin(C.LMDBGO_CMP_LENPREFIX)}
+)
+
+var errCmpFuncLimit = errors.New("comparison function limit reached")
+var errCmpFuncNil = errors.New("comparison function is nil")
+
+// cmpfuncs holds the Go functions registered with RegisterCmpFunc.  The index
+// of a function is the slot number passed to lmdbgoMDBCmpBridge.  A function
+// is stored before its CmpFunc is returned so comparisons load it without
+// locking.
+var cmpfuncs [C.LMDBGO_CMP_NSLOT]atomic.Value
+var cmpfuncsn int
+var cmpfuncslock sync.Mutex
+
+// RegisterCmpFunc allocates a CmpFunc which calls fn to compare keys.  The
+// slices passed to fn reference memory owned by LMDB and must not be modified
+// or retained after fn returns.  A registered function must behave
+// consistently for the lifetime of the process (and of the database) and
+// cannot be unregistered.
+//
+// Only a small, fixed number of Go functions may be registered because C
+// comparison functions take no context argument.  An error is returned if
+// the limit has been reached or if fn is nil.  Applications should register
+// each function once, when they are initialized.
+//
+// Comparing keys with a Go function is significantly slower than with a
+// builtin CmpFunc because each comparison calls into Go from C.
+func RegisterCmpFunc(fn func(a, b []byte) int) (*CmpFunc, error) {
+	if fn == nil {
+		return nil, errCmpFuncNil
+	}
+	cmpfuncslock.Lock()
+	defer cmpfuncslock.Unlock()
+	slot := C.lmdbgo_cmp_slot(C.size_t(cmpfuncsn))
+	if slot == nil {
+		return nil, errCmpFuncLimit
+	}
+	cmpfuncs[cmpfuncsn].Store(fn)
+	cmpfuncsn++
+	return &CmpFunc{slot}, nil
+}
+
+// lmdbgoMDBCmpBridge provides static C functions for MDB_cmp_func callbacks.
+// It performs dynamic dispatch to the function registered for slot.
+
+//export lmdbgoMDBCmpBridge
+func lmdbgoMDBCmpBridge(slot C.size_t, a, b *C.MDB_val) C.int {
+	fn := cmpfuncs[slot].Load().(func(a, b []byte) int)
+	return C.int(fn(cmpBytes(a), cmpBytes(b)))
+}
+
+func cmpBytes(val *C.MDB_val) []byte {
+	if val.mv_size == 0 {
+		return nil
+	}
+	return getBytes(val)
+}
+
+// SetCmp sets the function used to compare keys in dbi.  SetCmp must be
+// called before any data in dbi is accessed and the same function must be
+// used every time dbi is opened, by any process, otherwise the database will
+// be corrupted.  An error is returned if cmp is nil.
+//
+// See mdb_set_compare.
+func (txn *Txn) SetCmp(dbi DBI, cmp *CmpFunc) error {
+	if cmp == nil || cmp.fn == nil {
+		return errCmpFuncNil
+	}
+	ret := C.mdb_set_compare(txn._txn, C.MDB_dbi(dbi), cmp.fn)
+	return operrno("mdb_set_compare", ret)
+}
+
+// SetDupCmp sets the function used to compare the duplicate values of a key
+// in dbi, which must have been opened with the DupSort flag.  SetDupCmp has
+// the same restrictions as SetCmp.
+//
+// See mdb_set_dupsort.
+func (txn *Txn) SetDupCmp(dbi DBI, cmp *CmpFunc) error {
+	if cmp == nil || cmp.fn == nil {
+		return errCmpFuncNil
+	}
+	ret := C.mdb_set_dupsort(txn._txn, C.MDB_dbi(dbi), cmp.fn)
+	return operrno("mdb_set_dupsort", ret)
+}
+
+// OpenDBICmp opens a named database like OpenDBI and sets its comparison
+// functions before returning.  Either cmp or dupcmp may be nil, in which case
+// the corresponding default comparison is used.
+//
+// See mdb_dbi_open, mdb_set_compare, and mdb_set_dupsort.
+func (txn *Txn) OpenDBICmp(name string, flags uint, cmp, dupcmp *CmpFunc) (DBI, error) {
+	dbi, err := txn.OpenDBI(name, flags)
+	if err != nil {
+		return dbi, err
+	}
+	if cmp != nil {
+		err = txn.SetCmp(dbi, cmp)
+		if err != nil {
+			return dbi, err
+		}
+	}
+	if dupcmp != nil {
+		err = txn.SetDupCmp(dbi, dupcmp)
+		if err != nil {
+			return dbi, err
+		}
+	}
+	return dbi, nil
+}
@@ -0,0 +1,199 @@
+package lmdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"sync"
+	"testing"
+	"unsafe"
+)
+
+func TestCmpFunc_builtin(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	i64 := func(x int64) []byte {
+		b := make([]byte, 8)
+		*(*int64)(unsafe.Pointer(&b[0])) = x
+		return b
+	}
+	f64 := func(x float64) []byte {
+		b := make([]byte, 8)
+		*(*float64)(unsafe.Pointer(&b[0])) = x
+		return b
+	}
+	lp := func(segs ...string) []byte {
+		var b []byte
+		for _, s := range segs {
+			var n [binary.MaxVarintLen64]byte
+			b = append(b, n[:binary.PutUvarint(n[:], uint64(len(s)))]...)
+			b = append(b, s...)
+		}
+		return b
+	}
+
+	for _, test := range []struct {
+		name string
+		cmp  *CmpFunc
+		keys [][]byte // keys in the expected order
+	}{
+		{"int64", CmpInt64, [][]byte{
+			i64(math.MinInt64), i64(-300), i64(-1), i64(0), i64(1), i64(256), i64(math.MaxInt64),
+		}},
+		{"float64", CmpFloat64, [][]byte{
+			f64(math.Inf(-1)), f64(-2.5), f64(-0.5), f64(0), f64(0.25), f64(1e10), f64(math.Inf(1)), f64(math.NaN()),
+		}},
+		{"reverse", CmpReverse, [][]byte{
+			[]byte("z"), []byte("cab"), []byte("ca"), []byte("c"), []byte("b"), []byte("ab"),
+		}},
+		{"lengthPrefixed", CmpLengthPrefixed, [][]byte{
+			lp("a"), lp("a", "b"), lp("a", "zz"), lp("ab"), lp("ab", "a"), lp("b", "a"), lp("ba"),
+		}},
+	} {
+		err := env.Update(func(txn *Txn) (err error) {
+			dbi, err := txn.OpenDBICmp(test.name, Create, test.cmp, nil)
+			if err != nil {
+				return err
+			}
+			for i := len(test.keys) - 1; i >= 0; i-- {
+				err = txn.Put(dbi, test.keys[i], []byte{byte(i)}, 0)
+				if err != nil {
+					return err
+				}
+			}
+
+			cur, err := txn.OpenCursor(dbi)
+			if err != nil {
+				return err
+			}
+			defer cur.Close()
+			for i := range test.keys {
+				k, v, err := cur.Get(nil, nil, Next)
+				if err != nil {
+					return err
+				}
+				if !bytes.Equal(k, test.keys[i]) {
+					t.Errorf("%s: key %d: %x (!= %x)", test.name, i, k, test.keys[i])
+				}
+				if len(v) != 1 || int(v[0]) != i {
+					t.Errorf("%s: val %d: %v", test.name, i, v)
+				}
+			}
+			_, _, err = cur.Get(nil, nil, Next)
+			if !IsNotFound(err) {
+				t.Errorf("%s: expected end of database: %v", test.name, err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+	}
+}
+
+// lengthCmp orders keys by length and then lexicographically.  It is
+// registered once so that running the tests repeatedly does not exhaust the
+// limited number of registered functions.
+var lengthCmp struct {
+	once sync.Once
+	cmp  *CmpFunc
+	err  error
+}
+
+func registerLengthCmp() (*CmpFunc, error) {
+	lengthCmp.once.Do(func() {
+		lengthCmp.cmp, lengthCmp.err = RegisterCmpFunc(func(a, b []byte) int {
+			if len(a) != len(b) {
+				if len(a) < len(b) {
+					return -1
+				}
+				return 1
+			}
+			return bytes.Compare(a, b)
+		})
+	})
+	return lengthCmp.cmp, lengthCmp.err
+}
+
+func TestRegisterCmpFunc(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	cmp, err := registerLengthCmp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := []string{"b", "z", "aa", "ab", "aaa"}
+	vals := []string{"2", "1", "0", "10", "3"}
+	err = env.Update(func(txn *Txn) (err error) {
+		dbi, err := txn.OpenDBICmp("testdb", Create|DupSort, cmp, CmpReverse)
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			for _, v := range vals {
+				err = txn.Put(dbi, []byte(k), []byte(v), 0)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+		for _, expk := range keys {
+			for _, expv := range []string{"3", "2", "10", "1", "0"} {
+				k, v, err := cur.Get(nil, nil, Next)
+				if err != nil {
+					return err
+				}
+				if string(k) != expk || string(v) != expv {
+					t.Errorf("unexpected item: %q=%q (!= %q=%q)", k, v, expk, expv)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTxn_SetCmp_nil(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	err := env.Update(func(txn *Txn) (err error) {
+		dbi, err := txn.OpenDBI("testdb", Create|DupSort)
+		if err != nil {
+			return err
+		}
+		err = txn.SetCmp(dbi, nil)
+		if err != errCmpFuncNil {
+			t.Errorf("unexpected error: %v (!= %v)", err, errCmpFuncNil)
+		}
+		err = txn.SetDupCmp(dbi, &CmpFunc{})
+		if err != errCmpFuncNil {
+			t.Errorf("unexpected error: %v (!= %v)", err, errCmpFuncNil)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegisterCmpFunc_nil(t *testing.T) {
+	cmp, err := RegisterCmpFunc(nil)
+	if err != errCmpFuncNil {
+		t.Errorf("unexpected error: %v (!= %v)", err, errCmpFuncNil)
+	}
+	if cmp != nil {
+		t.Errorf("unexpected comparison function: %v", cmp)
+	}
+}
@@ -0,0 +1,59 @@
+//go:build go1.7
+// +build go1.7
+
+package lmdb
+
+import "context"
+
+// Context returns the context passed to Env.ViewContext, Env.UpdateContext,
+// or Env.RunTxnContext when txn (or its parent) was created.  If txn was not
+// created by one of those methods Context returns context.Background().
+func (txn *Txn) Context() context.Context {
+	if ctx, ok := txn.ctx.(context.Context); ok {
+		return ctx
+	}
+	return context.Background()
+}
+
+// ViewContext behaves like View but honors the deadline and cancellation of
+// ctx.  If ctx is done before the transaction begins ViewContext returns
+// ctx.Err() without calling fn.  Because a view has nothing to commit,
+// ViewContext returns the result of fn even if ctx is done by the time fn
+// returns.  The context is available to fn through Txn.Context.
+func (env *Env) ViewContext(ctx context.Context, fn TxnOp) error {
+	return env.runContext(ctx, false, Readonly, fn)
+}
+
+// UpdateContext behaves like Update but honors the deadline and cancellation
+// of ctx.  If ctx is done before the transaction begins UpdateContext returns
+// ctx.Err() without calling fn.  If ctx is done by the time fn returns the
+// transaction is aborted, instead of committed, and ctx.Err() is returned.
+// The context is available to fn through Txn.Context.
+//
+// Cancellation of ctx does not interrupt fn, which should check ctx itself
+// during long operations.
+func (env *Env) UpdateContext(ctx context.Context, fn TxnOp) error {
+	return env.runContext(ctx, true, 0, fn)
+}
+
+// RunTxnContext behaves like RunTxn but honors the deadline and cancellation
+// of ctx in the same way as UpdateContext, or as ViewContext if flags
+// contains Readonly.
+func (env *Env) RunTxnContext(ctx context.Context, flags uint, fn TxnOp) error {
+	return env.runContext(ctx, false, flags, fn)
+}
+
+func (env *Env) runContext(ctx context.Context, lock bool, flags uint, fn TxnOp) error {
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+	return env.run(lock, flags, func(txn *Txn) error {
+		txn.ctx = ctx
+		err := fn(txn)
+		if err != nil || flags&Readonly != 0 {
+			return err
+		}
+		return ctx.Err()
+	})
+}
@@ -0,0 +1,102 @@
+//go:build go1.7
+// +build go1.7
+
+package lmdb
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEnv_UpdateContext(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openDBI(env, "testdb", Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "v")
+	err = env.UpdateContext(ctx, func(txn *Txn) (err error) {
+		if txn.Context().Value(key{}) != "v" {
+			t.Errorf("unexpected context: %v", txn.Context())
+		}
+		return txn.Sub(func(txn *Txn) error {
+			if txn.Context() != ctx {
+				t.Errorf("unexpected subtransaction context: %v", txn.Context())
+			}
+			return txn.Put(dbi, []byte("k0"), []byte("v0"), 0)
+		})
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	// the transaction is aborted when ctx is cancelled before fn returns.
+	ctx, cancel := context.WithCancel(context.Background())
+	err = env.UpdateContext(ctx, func(txn *Txn) (err error) {
+		cancel()
+		return txn.Put(dbi, []byte("k1"), []byte("v1"), 0)
+	})
+	if err != context.Canceled {
+		t.Errorf("unexpected error: %v (!= %v)", err, context.Canceled)
+	}
+
+	// fn is not called when ctx is already done.
+	err = env.UpdateContext(ctx, func(txn *Txn) (err error) {
+		t.Errorf("fn called with a cancelled context")
+		return nil
+	})
+	if err != context.Canceled {
+		t.Errorf("unexpected error: %v (!= %v)", err, context.Canceled)
+	}
+
+	err = env.ViewContext(context.Background(), func(txn *Txn) (err error) {
+		_, err = txn.Get(dbi, []byte("k0"))
+		if err != nil {
+			return err
+		}
+		_, err = txn.Get(dbi, []byte("k1"))
+		if !IsNotFound(err) {
+			t.Errorf("expected NotFound: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	// a view returns the result of fn when ctx is cancelled before fn
+	// returns.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	var v []byte
+	err = env.ViewContext(ctx, func(txn *Txn) (err error) {
+		cancel()
+		v, err = txn.Get(dbi, []byte("k0"))
+		return err
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if string(v) != "v0" {
+		t.Errorf("unexpected value: %q (!= %q)", v, "v0")
+	}
+}
+
+func TestTxn_Context(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	err := env.View(func(txn *Txn) (err error) {
+		if txn.Context() != context.Background() {
+			t.Errorf("unexpected context: %v", txn.Context())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -8,6 +8,7 @@ package lmdb
 */
 import "C"
 import (
+	"errors"
 	"runtime"
 	"unsafe"
 )
@@ -221,6 +222,176 @@ func (c *Cursor) getVal2(setkey, setval []byte, op uint) error {
 	return operrno("mdb_cursor_get", ret)
 }
 
+// GetMany retrieves the values for keys by positioning c at each key with
+// the Set op, crossing the cgo boundary once regardless of the number of
+// keys.  When keys are sorted LMDB can often locate successive keys without
+// searching the database from its root, making GetMany faster than
+// Txn.GetMany.  The position of c after GetMany returns is unspecified.
+//
+// GetMany otherwise behaves like Txn.GetMany.
+//
+// See mdb_cursor_get.
+func (c *Cursor) GetMany(keys [][]byte, r *GetManyResult) error {
+	if len(keys) == 0 {
+		r.reset()
+		return nil
+	}
+	kdata, koff, vals, rets := r.pack(keys)
+	ret := C.lmdbgo_mdb_cursor_get_many(
+		c._c,
+		kdata, koff, C.size_t(len(keys)),
+		vals, rets,
+	)
+	r.unpack(c.txn)
+	return operrno("mdb_cursor_get", ret)
+}
+
+var errBatchOp = errors.New("op cannot be used to retrieve a batch")
+
+// batchNextOp returns the op used to move c after the first record of a
+// batch retrieved using op.
+func batchNextOp(op uint) (uint, bool) {
+	switch op {
+	case First, Next, GetCurrent:
+		return Next, true
+	case Last, Prev:
+		return Prev, true
+	case FirstDup, NextDup:
+		return NextDup, true
+	case LastDup, PrevDup:
+		return PrevDup, true
+	case NextNoDup:
+		return NextNoDup, true
+	case PrevNoDup:
+		return PrevNoDup, true
+	}
+	return 0, false
+}
+
+// GetBatch retrieves up to b.Cap() records into b, crossing the cgo boundary
+// once.  The first record is retrieved using op and the cursor continues in
+// the same direction for successive records (e.g. GetBatch(First, b) is
+// followed by Next and GetBatch(LastDup, b) is followed by PrevDup).
+// GetBatch(GetCurrent, b) includes the current record before moving with
+// Next, which allows a batch to start from a position established by Get with
+// the Set or SetRange ops.  Ops which require a key are not supported by
+// GetBatch.
+//
+// When the cursor is exhausted before b is filled GetBatch returns a short
+// batch and a nil error.  GetBatch returns an error for which IsNotFound
+// returns true only if no records could be retrieved.  After GetBatch returns
+// c is positioned at the last record in b.
+//
+// See mdb_cursor_get.
+func (c *Cursor) GetBatch(op uint, b *RecordBatch) error {
+	next, ok := batchNextOp(op)
+	if !ok {
+		b.unpack(c.txn, 0)
+		return errBatchOp
+	}
+	var n C.size_t
+	ret := C.lmdbgo_mdb_cursor_get_batch(
+		c._c,
+		C.MDB_cursor_op(op), C.MDB_cursor_op(next),
+		&b.ckeys[0], &b.cvals[0], C.size_t(len(b.ckeys)),
+		&n,
+	)
+	b.unpack(c.txn, int(n))
+	return operrno("mdb_cursor_get", ret)
+}
+
+// GetUint64 retrieves items from a database with integer keys, typically one
+// opened with the IntegerKey flag.  The integer k is used as the key for ops
+// which require one (Set, SetRange, etc) and is otherwise ignored.  An error
+// is returned if the key at the cursor position is not 8 bytes in length.
+//
+// GetUint64 otherwise behaves like Get.
+//
+// See mdb_cursor_get.
+func (c *Cursor) GetUint64(k uint64, op uint) (key uint64, val []byte, err error) {
+	ret := C.lmdbgo_mdb_cursor_get_uint64(
+		c._c,
+		C.uint64_t(k),
+		c.txn.key, c.txn.val,
+		C.MDB_cursor_op(op),
+	)
+	err = operrno("mdb_cursor_get", ret)
+	if err == nil {
+		key = k
+		if c.txn.key.mv_data != nil {
+			copy((*[8]byte)(unsafe.Pointer(&key))[:], getBytes(c.txn.key))
+		}
+		val = c.txn.bytes(c.txn.val)
+	}
+	*c.txn.key = C.MDB_val{}
+	*c.txn.val = C.MDB_val{}
+	return key, val, err
+}
+
+// GetUint32 retrieves items from a database with integer keys, typically one
+// opened with the IntegerKey flag.  The integer k is used as the key for ops
+// which require one (Set, SetRange, etc) and is otherwise ignored.  An error
+// is returned if the key at the cursor position is not 4 bytes in length.
+//
+// GetUint32 otherwise behaves like Get.
+//
+// See mdb_cursor_get.
+func (c *Cursor) GetUint32(k uint32, op uint) (key uint32, val []byte, err error) {
+	ret := C.lmdbgo_mdb_cursor_get_uint32(
+		c._c,
+		C.uint32_t(k),
+		c.txn.key, c.txn.val,
+		C.MDB_cursor_op(op),
+	)
+	err = operrno("mdb_cursor_get", ret)
+	if err == nil {
+		key = k
+		if c.txn.key.mv_data != nil {
+			copy((*[4]byte)(unsafe.Pointer(&key))[:], getBytes(c.txn.key))
+		}
+		val = c.txn.bytes(c.txn.val)
+	}
+	*c.txn.key = C.MDB_val{}
+	*c.txn.val = C.MDB_val{}
+	return key, val, err
+}
+
+// PutUint64 stores an item with integer key k in the database, typically one
+// opened with the IntegerKey flag.
+//
+// See mdb_cursor_put.
+func (c *Cursor) PutUint64(k uint64, val []byte, flags uint) error {
+	vdata, vn := valBytes(val)
+	ret := C.lmdbgo_mdb_cursor_put_uint64(
+		c._c,
+		C.uint64_t(k),
+		(*C.char)(unsafe.Pointer(&vdata[0])), C.size_t(vn),
+		C.uint(flags),
+	)
+	if ret == success && c.txn.changes != nil {
+		c.txn.recordUint64(c.DBI(), k)
+	}
+	return operrno("mdb_cursor_put", ret)
+}
+
+// PutUint32 stores an item with integer key k in the database, typically one
+// opened with the IntegerKey flag.
+//
+// See mdb_cursor_put.
+func (c *Cursor) PutUint32(k uint32, val []byte, flags uint) error {
+	vdata, vn := valBytes(val)
+	ret := C.lmdbgo_mdb_cursor_put_uint32(
+		c._c,
+		C.uint32_t(k),
+		(*C.char)(unsafe.Pointer(&vdata[0])), C.size_t(vn),
+		C.uint(flags),
+	)
+	if ret == success && c.txn.changes != nil {
+		c.txn.recordUint32(c.DBI(), k)
+	}
+	return operrno("mdb_cursor_put", ret)
+}
+
 func (c *Cursor) putNilKey(flags uint) error {
 	ret := C.lmdbgo_mdb_cursor_put2(c._c, nil, 0, nil, 0, C.uint(flags))
 	return operrno("mdb_cursor_put", ret)
@@ -243,9 +414,37 @@ func (c *Cursor) Put(key, val []byte, flags uint) error {
 		(*C.char)(unsafe.Pointer(&val[0])), C.size_t(len(val)),
 		C.uint(flags),
 	)
+	if ret == success && c.txn.changes != nil {
+		c.txn.record(c.DBI(), ChangePut, key)
+	}
 	return operrno("mdb_cursor_put", ret)
 }
 
+// PutBatch stores the key-value pairs in b using flags, crossing the cgo
+// boundary once regardless of the number of pairs.  After PutBatch returns c
+// is positioned at the last pair stored.
+//
+// PutBatch otherwise behaves like Txn.PutBatch.
+//
+// See mdb_cursor_put.
+func (c *Cursor) PutBatch(b *PutBuffer, flags uint) (n int, err error) {
+	if b.Len() == 0 {
+		return 0, nil
+	}
+	data, off := b.pack()
+	var count C.size_t
+	ret := C.lmdbgo_mdb_cursor_put_batch(
+		c._c,
+		data, off, C.size_t(b.Len()),
+		C.uint(flags),
+		&count,
+	)
+	if c.txn.changes != nil {
+		c.txn.recordBatch(c.DBI(), b, int(count))
+	}
+	return int(count), operrno("mdb_cursor_put", ret)
+}
+
 // PutReserve returns a []byte of length n that can be written to, potentially
 // avoiding a memcopy.  The returned byte slice is only valid in txn's thread,
 // before it has terminated.
@@ -266,6 +465,9 @@ func (c *Cursor) PutReserve(key []byte, n int, flags uint) ([]byte, error) {
 		*c.txn.val = C.MDB_val{}
 		return nil, err
 	}
+	if c.txn.changes != nil {
+		c.txn.record(c.DBI(), ChangePut, key)
+	}
 	b := getBytes(c.txn.val)
 	*c.txn.val = C.MDB_val{}
 	return b, nil
@@ -291,6 +493,9 @@ func (c *Cursor) PutMulti(key []byte, page []byte, stride int, flags uint) error
 		(*C.char)(unsafe.Pointer(&page[0])), C.size_t(vn), C.size_t(stride),
 		C.uint(flags|C.MDB_MULTIPLE),
 	)
+	if ret == success && c.txn.changes != nil {
+		c.txn.record(c.DBI(), ChangePut, key)
+	}
 	return operrno("mdb_cursor_put", ret)
 }
 
@@ -298,10 +503,30 @@ func (c *Cursor) PutMulti(key []byte, page []byte, stride int, flags uint) error
 //
 // See mdb_cursor_del.
 func (c *Cursor) Del(flags uint) error {
+	if c.txn.changes != nil {
+		return c.delRecord(flags)
+	}
 	ret := C.mdb_cursor_del(c._c, C.uint(flags))
 	return operrno("mdb_cursor_del", ret)
 }
 
+// delRecord deletes the current item like Del and records the deleted key
+// for the observers of the Env.
+func (c *Cursor) delRecord(flags uint) error {
+	key, _, err := c.Get(nil, nil, GetCurrent)
+	if err != nil {
+		return err
+	}
+	// When txn.RawRead is true key refers to the page modified by
+	// mdb_cursor_del, so it must be copied first.
+	key = append([]byte(nil), key...)
+	ret := C.mdb_cursor_del(c._c, C.uint(flags))
+	if ret == success {
+		c.txn.recordOwned(c.DBI(), ChangeDel, key)
+	}
+	return operrno("mdb_cursor_del", ret)
+}
+
 // Count returns the number of duplicates for the current key.
 //
 // See mdb_cursor_count.
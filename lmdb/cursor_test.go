@@ -160,6 +160,63 @@ func TestCursor_bytesBuffer(t *testing.T) {
 	}
 }
 
+func TestCursor_PutBatch(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	err := env.Update(func(txn *Txn) (err error) {
+		dbi, err := txn.OpenDBI("testdb", Create)
+		if err != nil {
+			return err
+		}
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+
+		b := new(PutBuffer)
+		b.Add([]byte("k0"), []byte("v0"))
+		b.Add([]byte("k1"), []byte("v1"))
+		b.Add([]byte("k3"), []byte("v3"))
+		b.Add([]byte("k2"), []byte("v2"))
+		n, err := cur.PutBatch(b, Append)
+		if !IsErrno(err, KeyExist) {
+			t.Errorf("unexpected error: %v (!= %v)", err, KeyExist)
+		}
+		if n != 3 {
+			t.Errorf("unexpected number of items stored: %d (!= 3)", n)
+		}
+
+		k, v, err := cur.Get(nil, nil, GetCurrent)
+		if err != nil {
+			return err
+		}
+		if string(k) != "k3" || string(v) != "v3" {
+			t.Errorf("unexpected cursor position: %q=%q", k, v)
+		}
+
+		var items []string
+		for op := uint(First); ; op = Next {
+			k, v, err := cur.Get(nil, nil, op)
+			if IsNotFound(err) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			items = append(items, string(k)+"="+string(v))
+		}
+		if len(items) != 3 || items[0] != "k0=v0" || items[1] != "k1=v1" || items[2] != "k3=v3" {
+			t.Errorf("unexpected items: %q", items)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
 func TestCursor_PutReserve(t *testing.T) {
 	env := setup(t)
 	defer clean(env, t)
@@ -543,6 +600,319 @@ func TestCursor_PutMulti(t *testing.T) {
 	}
 }
 
+func TestCursor_GetBatch(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	var dbi DBI
+	err := env.Update(func(txn *Txn) (err error) {
+		dbi, err = txn.OpenDBI("testdb", Create)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 25; i++ {
+			k := fmt.Sprintf("k%02d", i)
+			err = txn.Put(dbi, []byte(k), []byte(k[1:]), 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := NewRecordBatch(10)
+	for _, raw := range []bool{false, true} {
+		err = env.View(func(txn *Txn) (err error) {
+			txn.RawRead = raw
+
+			cur, err := txn.OpenCursor(dbi)
+			if err != nil {
+				return err
+			}
+			defer cur.Close()
+
+			// the batches returned by GetBatch and the first key in each.
+			for _, test := range []struct {
+				op    uint
+				n     int
+				first int
+			}{
+				{First, 10, 0},
+				{Next, 10, 10},
+				{Next, 5, 20},
+				{Next, 0, 0},
+				{Last, 10, 24},
+				{Prev, 10, 14},
+				{Prev, 5, 4},
+				{Prev, 0, 0},
+			} {
+				err = cur.GetBatch(test.op, b)
+				if test.n == 0 {
+					if !IsNotFound(err) {
+						t.Errorf("op %d: expected NotFound: %v", test.op, err)
+					}
+				} else if err != nil {
+					return err
+				}
+				if b.Len() != test.n {
+					t.Errorf("op %d: unexpected batch length: %d (!= %d)", test.op, b.Len(), test.n)
+					continue
+				}
+				for i := 0; i < b.Len(); i++ {
+					j := test.first + i
+					if test.op == Last || test.op == Prev {
+						j = test.first - i
+					}
+					k := fmt.Sprintf("k%02d", j)
+					if string(b.Key(i)) != k || string(b.Val(i)) != k[1:] {
+						t.Errorf("op %d: unexpected record %d: %q=%q (!= %q)", test.op, i, b.Key(i), b.Val(i), k)
+					}
+				}
+			}
+
+			_, _, err = cur.Get([]byte("k17"), nil, Set)
+			if err != nil {
+				return err
+			}
+			err = cur.GetBatch(GetCurrent, b)
+			if err != nil {
+				return err
+			}
+			if b.Len() != 8 || string(b.Key(0)) != "k17" || string(b.Key(7)) != "k24" {
+				t.Errorf("unexpected batch: %d records from %q", b.Len(), b.Key(0))
+			}
+
+			err = cur.GetBatch(SetRange, b)
+			if err == nil {
+				t.Errorf("expected an error")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestCursor_GetMany(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	var dbi DBI
+	err := env.Update(func(txn *Txn) (err error) {
+		dbi, err = txn.OpenDBI("testdb", Create)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 100; i += 2 {
+			k := []byte(fmt.Sprintf("k%03d", i))
+			err = txn.Put(dbi, k, k[1:], 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keys [][]byte
+	for i := 0; i < 100; i += 3 {
+		keys = append(keys, []byte(fmt.Sprintf("k%03d", i)))
+	}
+	res := new(GetManyResult)
+	err = env.View(func(txn *Txn) (err error) {
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+
+		err = cur.GetMany(keys, res)
+		if err != nil {
+			return err
+		}
+		for i := range keys {
+			if i%2 != 0 {
+				if !IsNotFound(res.Err(i)) {
+					t.Errorf("key %q: expected NotFound: %v", keys[i], res.Err(i))
+				}
+				continue
+			}
+			if res.Err(i) != nil {
+				t.Errorf("key %q: %v", keys[i], res.Err(i))
+			}
+			if !bytes.Equal(res.Val(i), keys[i][1:]) {
+				t.Errorf("key %q: unexpected value: %q (!= %q)", keys[i], res.Val(i), keys[i][1:])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCursor_GetUint64(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	err := env.Update(func(txn *Txn) (err error) {
+		dbi, err := txn.OpenDBI("testdb", Create|IntegerKey)
+		if err != nil {
+			return err
+		}
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+
+		// keys are inserted out of order to make sure they are sorted
+		// numerically instead of lexicographically.
+		for _, k := range []uint64{300, 1, 20, 1 << 33} {
+			err = cur.PutUint64(k, []byte(fmt.Sprint(k)), 0)
+			if err != nil {
+				return err
+			}
+		}
+
+		var keys []uint64
+		for op := uint(First); ; op = Next {
+			k, v, err := cur.GetUint64(0, op)
+			if IsNotFound(err) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if string(v) != fmt.Sprint(k) {
+				t.Errorf("unexpected value for key %d: %q", k, v)
+			}
+			keys = append(keys, k)
+		}
+		if !reflect.DeepEqual(keys, []uint64{1, 20, 300, 1 << 33}) {
+			t.Errorf("unexpected keys: %v", keys)
+		}
+
+		k, v, err := cur.GetUint64(20, Set)
+		if err != nil {
+			return err
+		}
+		if k != 20 || string(v) != "20" {
+			t.Errorf("unexpected item: %d=%q", k, v)
+		}
+		k, v, err = cur.GetUint64(21, SetRange)
+		if err != nil {
+			return err
+		}
+		if k != 300 || string(v) != "300" {
+			t.Errorf("unexpected item: %d=%q", k, v)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCursor_GetUint32(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	err := env.Update(func(txn *Txn) (err error) {
+		dbi, err := txn.OpenDBI("testdb", Create|IntegerKey)
+		if err != nil {
+			return err
+		}
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+
+		for _, k := range []uint32{300, 1, 20, 1 << 30} {
+			err = cur.PutUint32(k, []byte(fmt.Sprint(k)), 0)
+			if err != nil {
+				return err
+			}
+		}
+
+		var keys []uint32
+		for op := uint(Last); ; op = Prev {
+			k, _, err := cur.GetUint32(0, op)
+			if IsNotFound(err) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			keys = append(keys, k)
+		}
+		if !reflect.DeepEqual(keys, []uint32{1 << 30, 300, 20, 1}) {
+			t.Errorf("unexpected keys: %v", keys)
+		}
+
+		_, _, err = cur.GetUint64(0, First)
+		if !IsErrno(err, BadValSize) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCursor_PutMulti_IntegerDup(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	vals := []uint64{1 << 40, 7, 1 << 9, 2}
+	err := env.Update(func(txn *Txn) (err error) {
+		dbi, err := txn.OpenDBI("testdb", Create|IntegerKey|DupSort|DupFixed|IntegerDup)
+		if err != nil {
+			return err
+		}
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+
+		multi := WrapMultiUint64(vals)
+		err = cur.PutMulti([]byte("12345678"), multi.Page(), multi.Stride(), 0)
+		if err != nil {
+			return err
+		}
+
+		_, _, err = cur.Get(nil, nil, First)
+		if err != nil {
+			return err
+		}
+		_, page, err := cur.Get(nil, nil, GetMultiple)
+		if err != nil {
+			return err
+		}
+		multi = WrapMulti(page, 8)
+		var dbvals []uint64
+		for i := 0; i < multi.Len(); i++ {
+			dbvals = append(dbvals, multi.Uint64(i))
+		}
+		if !reflect.DeepEqual(dbvals, []uint64{2, 7, 1 << 9, 1 << 40}) {
+			t.Errorf("unexpected values: %v", dbvals)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
 func TestCursor_Del(t *testing.T) {
 	env := setup(t)
 	defer clean(env, t)
@@ -70,6 +70,24 @@ type Env struct {
 
 	ckey *C.MDB_val
 	cval *C.MDB_val
+
+	// proxy executes the operations of ProxyTxns, which are serialized by
+	// proxyLock.  The proxy thread itself is guarded by proxyMut, which is
+	// not held while a ProxyTxn is active.
+	proxy       *proxyThread
+	proxyLock   sync.Mutex
+	proxyMut    sync.Mutex
+	proxyClosed bool
+
+	observers observers
+
+	// readers tracks read transactions while env has a Watchdog.
+	readers readerTracker
+
+	// names holds the names of open databases, which identify their
+	// sequences in seqs.
+	names dbiNames
+	seqs  seqCache
 }
 
 // NewEnv allocates and initializes a new Env.
@@ -167,6 +185,9 @@ func (env *Env) close() bool {
 		return false
 	}
 
+	// Operations of a ProxyTxn must not be executed once the Env is closed.
+	env.stopProxy()
+
 	env.closeLock.Lock()
 	C.mdb_env_close(env._env)
 	env._env = nil
@@ -519,4 +540,5 @@ func (env *Env) run(lock bool, flags uint, fn TxnOp) error {
 // See mdb_dbi_close.
 func (env *Env) CloseDBI(db DBI) {
 	C.mdb_dbi_close(env._env, C.MDB_dbi(db))
+	env.names.del(db)
 }
@@ -378,6 +378,124 @@ func TestEnv_ReaderCheck(t *testing.T) {
 	}
 }
 
+func TestEnv_Readers(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	readers, err := env.Readers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(readers) != 0 {
+		t.Errorf("unexpected readers: %v", readers)
+	}
+
+	txn, err := env.BeginTxn(nil, Readonly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	id := int64(txn.ID())
+
+	for i := 0; i < 3; i++ {
+		err = env.Update(func(txn *Txn) (err error) {
+			dbi, err := txn.OpenRoot(0)
+			if err != nil {
+				return err
+			}
+			return txn.Put(dbi, []byte("k"), []byte("v"), 0)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	readers, err = env.Readers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(readers) != 1 {
+		t.Fatalf("unexpected readers: %v", readers)
+	}
+	r := readers[0]
+	if r.PID != os.Getpid() {
+		t.Errorf("unexpected pid: %d (!= %d)", r.PID, os.Getpid())
+	}
+	if r.TxnID != id {
+		t.Errorf("unexpected txnid: %d (!= %d)", r.TxnID, id)
+	}
+	if r.Stale {
+		t.Errorf("reader is stale")
+	}
+	info, err := env.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Lag(info.LastTxnID) != 3 {
+		t.Errorf("unexpected lag: %d (!= %d)", r.Lag(info.LastTxnID), 3)
+	}
+	lag, err := env.MaxReaderLag()
+	if err != nil {
+		t.Error(err)
+	} else if lag != 3 {
+		t.Errorf("unexpected max lag: %d (!= %d)", lag, 3)
+	}
+
+	// the slot remains held by the thread after a reset but has no snapshot.
+	txn.Reset()
+	readers, err = env.Readers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(readers) != 1 || readers[0].Active() {
+		t.Errorf("unexpected readers: %v", readers)
+	}
+	lag, err = env.MaxReaderLag()
+	if err != nil {
+		t.Error(err)
+	} else if lag != 0 {
+		t.Errorf("unexpected max lag: %d (!= %d)", lag, 0)
+	}
+}
+
+func TestParseReader(t *testing.T) {
+	for _, test := range []struct {
+		line string
+		r    ReaderInfo
+		ok   bool
+	}{
+		{"    pid     thread     txnid\n", ReaderInfo{}, false},
+		{"(no active readers)\n", ReaderInfo{}, false},
+		{"     12345 7f0a1b2c3d40 17\n", ReaderInfo{PID: 12345, ThreadID: 0x7f0a1b2c3d40, TxnID: 17}, true},
+		{"     12345 7f0a1b2c3d40 -\n", ReaderInfo{PID: 12345, ThreadID: 0x7f0a1b2c3d40, TxnID: -1}, true},
+	} {
+		r, ok, err := parseReader(test.line)
+		if err != nil {
+			t.Errorf("%q: %v", test.line, err)
+			continue
+		}
+		if ok != test.ok || r != test.r {
+			t.Errorf("%q: unexpected reader: %v %v (!= %v %v)", test.line, r, ok, test.r, test.ok)
+		}
+	}
+	for _, line := range []string{
+		"x y z\n",
+		"    pid     thread\n",
+		"    pid     thread     txnid       lag\n",
+		"(no readers)\n",
+		"     12345 7f0a1b2c3d40\n",
+		"     12345 7f0a1b2c3d40 17 3\n",
+		"     -x 7f0a1b2c3d40 17\n",
+		"     12345 thread 17\n",
+		"     12345 7f0a1b2c3d40 0x11\n",
+	} {
+		_, ok, err := parseReader(line)
+		if err == nil || ok {
+			t.Errorf("%q: expected an error", line)
+		}
+	}
+}
+
 func TestEnv_Copy(t *testing.T) {
 	testEnvCopy(t, 0, false, false)
 }
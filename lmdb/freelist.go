@@ -0,0 +1,187 @@
+package lmdb
+
+/*
+#include "lmdb.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"sort"
+	"unsafe"
+)
+
+// errFreelistCorrupted is returned by Txn.Freelist when a record is not a
+// transaction id and a list of pages which fits within its value.
+var errFreelistCorrupted = errors.New("freelist record is malformed")
+
+// FreelistTxn describes the pages freed by a transaction which have not been
+// reused.
+type FreelistTxn struct {
+	TxnID   int64 // Transaction which freed the pages
+	Pages   int64 // Number of pages
+	MaxSpan int64 // Longest run of consecutive pages
+}
+
+// FreelistStat summarizes the pages in the freelist of an environment.  The
+// freelist holds the pages freed by every committed transaction, including
+// pages which are still referenced by the snapshots of active readers.  Such
+// pages are counted as free but cannot be reused by writers until no reader
+// views a snapshot older than the transaction which freed them.
+type FreelistStat struct {
+	Txns []FreelistTxn
+
+	FreePages int64 // Total number of free pages
+	MaxSpan   int64 // Longest run of consecutive free pages
+	Spans     int64 // Number of runs of consecutive free pages
+
+	// Fragmentation is the fraction of free pages outside of the longest
+	// run.  Fragmentation is zero if the free pages are contiguous, or if
+	// there are no free pages, and approaches one as free pages become
+	// scattered.  Values requiring more than one page (overflow pages) can
+	// only be stored in a span of free pages, so a fragmented freelist may
+	// cause the database to grow even though it contains free pages.
+	Fragmentation float64
+}
+
+// Freelist calls fn with the page numbers of each record in the freelist of
+// the environment, which is stored in database 0.  Records are visited in
+// order of the transactions which freed them, and pages are given in
+// descending order.  The pages slice is reused and must not be retained
+// after fn returns.  If fn returns an error iteration stops and the error is
+// returned.  If a record is malformed Freelist returns an error without
+// calling fn for the record.
+func (txn *Txn) Freelist(fn func(txnid int64, pages []uint64) error) error {
+	cur, err := txn.OpenCursor(freeDBI)
+	if err != nil {
+		return err
+	}
+	defer cur.Close()
+
+	var pages []uint64
+	for op := uint(First); ; op = Next {
+		err = cur.getVal0(op)
+		if IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var txnid int64
+		txnid, pages, err = freelistRecord(getBytes(txn.key), getBytes(txn.val), pages[:0])
+		*txn.key = C.MDB_val{}
+		*txn.val = C.MDB_val{}
+		if err != nil {
+			return err
+		}
+
+		err = fn(txnid, pages)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// freelistRecord decodes a freelist record, appending its pages to pages.
+// The key of a record is a transaction id and the value is a count of pages
+// followed by the pages, each a size_t.
+func freelistRecord(k, v []byte, pages []uint64) (int64, []uint64, error) {
+	const size = int(unsafe.Sizeof(C.size_t(0)))
+	if len(k) != size || len(v) < size {
+		return 0, pages, errFreelistCorrupted
+	}
+	txnid := int64(*(*C.size_t)(unsafe.Pointer(&k[0])))
+	n := uint64(*(*C.size_t)(unsafe.Pointer(&v[0])))
+	if n > uint64(len(v)/size-1) {
+		return txnid, pages, errFreelistCorrupted
+	}
+	for i := 1; i <= int(n); i++ {
+		pg := *(*C.size_t)(unsafe.Pointer(&v[i*size]))
+		pages = append(pages, uint64(pg))
+	}
+	return txnid, pages, nil
+}
+
+// FreelistStat returns a summary of the freelist viewed by txn.
+func (txn *Txn) FreelistStat() (*FreelistStat, error) {
+	return txn.FreelistStatFunc(nil)
+}
+
+// FreelistStatFunc is like FreelistStat but also calls fn with the summary
+// and pages of each record as it is read, so that callers needing both the
+// records and the summary only read the freelist once.  The pages slice is
+// reused as it is by Freelist.  If fn returns an error it is returned
+// immediately.
+func (txn *Txn) FreelistStatFunc(fn func(t FreelistTxn, pages []uint64) error) (*FreelistStat, error) {
+	stat := new(FreelistStat)
+	var all pageList
+	err := txn.Freelist(func(txnid int64, pages []uint64) error {
+		stat.Txns = append(stat.Txns, FreelistTxn{
+			TxnID:   txnid,
+			Pages:   int64(len(pages)),
+			MaxSpan: maxSpan(pages),
+		})
+		all = append(all, pages...)
+		if fn != nil {
+			return fn(stat.Txns[len(stat.Txns)-1], pages)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Pages freed by different transactions may be adjacent so spans are
+	// computed over the entire freelist.
+	sort.Sort(all)
+	stat.FreePages = int64(len(all))
+	var span int64
+	for i := range all {
+		if i > 0 && all[i] == all[i-1]-1 {
+			span++
+		} else {
+			span = 1
+			stat.Spans++
+		}
+		if span > stat.MaxSpan {
+			stat.MaxSpan = span
+		}
+	}
+	if stat.FreePages > 0 {
+		stat.Fragmentation = 1 - float64(stat.MaxSpan)/float64(stat.FreePages)
+	}
+	return stat, nil
+}
+
+// FreelistStat returns a summary of the freelist of the environment.
+func (env *Env) FreelistStat() (stat *FreelistStat, err error) {
+	err = env.View(func(txn *Txn) (err error) {
+		stat, err = txn.FreelistStat()
+		return err
+	})
+	return stat, err
+}
+
+// maxSpan returns the longest run of consecutive pages in a list of pages in
+// descending order.
+func maxSpan(pages []uint64) int64 {
+	var max, span int64
+	for i := range pages {
+		if i > 0 && pages[i] == pages[i-1]-1 {
+			span++
+		} else {
+			span = 1
+		}
+		if span > max {
+			max = span
+		}
+	}
+	return max
+}
+
+// pageList sorts page numbers in descending order.
+type pageList []uint64
+
+func (l pageList) Len() int           { return len(l) }
+func (l pageList) Less(i, j int) bool { return l[i] > l[j] }
+func (l pageList) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
@@ -0,0 +1,186 @@
+package lmdb
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+func TestTxn_FreelistStat(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	stat, err := env.FreelistStat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stat.Txns) != 0 || stat.FreePages != 0 || stat.Fragmentation != 0 {
+		t.Errorf("unexpected freelist: %#v", stat)
+	}
+
+	dbi, err := openDBI(env, "testdb", Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		err = env.Update(func(txn *Txn) (err error) {
+			for j := 0; j < 1000; j++ {
+				k := []byte(fmt.Sprintf("%03d%04d", i, j))
+				err = txn.Put(dbi, k, make([]byte, 100), 0)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = env.Update(func(txn *Txn) (err error) {
+		return txn.Drop(dbi, false)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.View(func(txn *Txn) (err error) {
+		stat, err = txn.FreelistStat()
+		if err != nil {
+			return err
+		}
+		dbstat, err := txn.Stat(0)
+		if err != nil {
+			return err
+		}
+		if uint64(len(stat.Txns)) != dbstat.Entries {
+			t.Errorf("unexpected freelist records: %d (!= %d)", len(stat.Txns), dbstat.Entries)
+		}
+
+		var n int64
+		err = txn.Freelist(func(txnid int64, pages []uint64) error {
+			if txnid <= 0 || txnid > int64(txn.ID()) {
+				t.Errorf("unexpected txnid: %d", txnid)
+			}
+			for i := 1; i < len(pages); i++ {
+				if pages[i] >= pages[i-1] {
+					t.Errorf("pages not in descending order: %d", pages)
+					break
+				}
+			}
+			n += int64(len(pages))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if n != stat.FreePages {
+			t.Errorf("unexpected free pages: %d (!= %d)", stat.FreePages, n)
+		}
+
+		var records []FreelistTxn
+		stat2, err := txn.FreelistStatFunc(func(ftxn FreelistTxn, pages []uint64) error {
+			if int64(len(pages)) != ftxn.Pages {
+				t.Errorf("unexpected pages: %d (!= %d)", len(pages), ftxn.Pages)
+			}
+			records = append(records, ftxn)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(records, stat.Txns) || !reflect.DeepEqual(stat2, stat) {
+			t.Errorf("unexpected freelist: %#v (!= %#v)", stat2, stat)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stat.FreePages == 0 {
+		t.Fatalf("no free pages")
+	}
+	var n, span int64
+	for _, txn := range stat.Txns {
+		n += txn.Pages
+		if txn.MaxSpan > span {
+			span = txn.MaxSpan
+		}
+	}
+	if n != stat.FreePages {
+		t.Errorf("unexpected free pages: %d (!= %d)", stat.FreePages, n)
+	}
+	if stat.MaxSpan < span || stat.MaxSpan > stat.FreePages {
+		t.Errorf("unexpected max span: %d", stat.MaxSpan)
+	}
+	if stat.Spans < 1 {
+		t.Errorf("unexpected spans: %d", stat.Spans)
+	}
+	if stat.Fragmentation < 0 || stat.Fragmentation >= 1 {
+		t.Errorf("unexpected fragmentation: %v", stat.Fragmentation)
+	}
+	t.Logf("free pages: %d, spans: %d, max span: %d, fragmentation: %.3f", stat.FreePages, stat.Spans, stat.MaxSpan, stat.Fragmentation)
+}
+
+func TestMaxSpan(t *testing.T) {
+	for _, test := range []struct {
+		pages []uint64
+		span  int64
+	}{
+		{nil, 0},
+		{[]uint64{7}, 1},
+		{[]uint64{9, 7, 5}, 1},
+		{[]uint64{9, 8, 7, 5, 4}, 3},
+		{[]uint64{9, 7, 6, 5, 4, 1}, 4},
+	} {
+		span := maxSpan(test.pages)
+		if span != test.span {
+			t.Errorf("%d: unexpected span: %d (!= %d)", test.pages, span, test.span)
+		}
+	}
+}
+
+func TestFreelistRecord(t *testing.T) {
+	if unsafe.Sizeof(uintptr(0)) != 8 {
+		t.Skip("test records assume an 8 byte size_t")
+	}
+	// ids encodes a sequence of size_t values in native byte order.
+	ids := func(x ...uint64) []byte {
+		if len(x) == 0 {
+			return []byte{}
+		}
+		return append([]byte(nil), (*[1 << 20]byte)(unsafe.Pointer(&x[0]))[:8*len(x)]...)
+	}
+	for i, test := range []struct {
+		k, v  []byte
+		txnid int64
+		pages []uint64
+		err   error
+	}{
+		{ids(3), ids(2, 9, 8), 3, []uint64{9, 8}, nil},
+		{ids(3), ids(0), 3, nil, nil},
+		{ids(3), ids(1, 9, 8), 3, []uint64{9}, nil},
+		{ids(3), ids(3, 9, 8), 0, nil, errFreelistCorrupted},
+		{ids(3), ids(1<<63, 9), 0, nil, errFreelistCorrupted},
+		{ids(3), ids(), 0, nil, errFreelistCorrupted},
+		{ids(3), []byte{1, 0, 0}, 0, nil, errFreelistCorrupted},
+		{[]byte{3}, ids(1, 9), 0, nil, errFreelistCorrupted},
+	} {
+		txnid, pages, err := freelistRecord(test.k, test.v, nil)
+		if err != test.err {
+			t.Errorf("%d: unexpected error: %v (!= %v)", i, err, test.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if txnid != test.txnid {
+			t.Errorf("%d: unexpected txnid: %d (!= %d)", i, txnid, test.txnid)
+		}
+		if !reflect.DeepEqual(pages, test.pages) {
+			t.Errorf("%d: unexpected pages: %d (!= %d)", i, pages, test.pages)
+		}
+	}
+}
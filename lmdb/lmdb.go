@@ -108,6 +108,11 @@ uses a finalizer to abort unreachable Txn objects.  But of course, applications
 must still be careful not to leak unterminated Txn objects in a way such that
 they fail get garbage collected.
 
+A Watchdog, started with Env.StartWatchdog, calls Env.ReaderCheck periodically
+and reports read transactions which have been open too long or which view a
+snapshot too far behind the last committed transaction, which helps to locate
+leaked transactions.
+
 
 Caveats
 
@@ -127,6 +132,10 @@ the runtime's thread locking implementation.  In such situations updates
 desired by the goroutine in question must be proxied by a goroutine with a
 known state (i.e.  "locked" or "unlocked").  See the included examples for more
 details about dealing with such situations.
+
+The ProxyTxn type, created with Env.BeginProxyTxn, implements such a proxy.
+Operations on a ProxyTxn are executed by a goroutine owned by the Env which is
+locked to its thread, so a ProxyTxn may be used from any goroutine.
 */
 package lmdb
 
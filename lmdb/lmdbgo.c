@@ -1,6 +1,8 @@
 /* lmdbgo.c
  * Helper utilities for github.com/bmatsuo/lmdb-go/lmdb
  * */
+#include <stdint.h>
+#include <string.h>
 #include "lmdb.h"
 #include "lmdbgo.h"
 #include "_cgo_export.h"
@@ -80,3 +82,230 @@ int lmdbgo_mdb_cursor_get2(MDB_cursor *cur, char *kdata, size_t kn, char *vdata,
     LMDBGO_SET_VAL(val, vn, vdata);
     return mdb_cursor_get(cur, key, val, op);
 }
+
+int lmdbgo_mdb_get_many(MDB_txn *txn, MDB_dbi dbi, char *kdata, size_t *koff, size_t n, MDB_val *vals, int *rets) {
+    MDB_val key;
+    size_t i;
+    for (i = 0; i < n; i++) {
+        LMDBGO_SET_VAL(&key, koff[i+1] - koff[i], kdata + koff[i]);
+        rets[i] = mdb_get(txn, dbi, &key, &vals[i]);
+        if (rets[i] != MDB_SUCCESS && rets[i] != MDB_NOTFOUND)
+            return rets[i];
+    }
+    return MDB_SUCCESS;
+}
+
+int lmdbgo_mdb_cursor_get_many(MDB_cursor *cur, char *kdata, size_t *koff, size_t n, MDB_val *vals, int *rets) {
+    MDB_val key;
+    size_t i;
+    for (i = 0; i < n; i++) {
+        LMDBGO_SET_VAL(&key, koff[i+1] - koff[i], kdata + koff[i]);
+        rets[i] = mdb_cursor_get(cur, &key, &vals[i], MDB_SET);
+        if (rets[i] != MDB_SUCCESS && rets[i] != MDB_NOTFOUND)
+            return rets[i];
+    }
+    return MDB_SUCCESS;
+}
+
+int lmdbgo_mdb_cursor_get_batch(MDB_cursor *cur, MDB_cursor_op op, MDB_cursor_op next, MDB_val *keys, MDB_val *vals, size_t n, size_t *count) {
+    int rc = MDB_SUCCESS;
+    size_t i;
+    for (i = 0; i < n; i++) {
+        rc = mdb_cursor_get(cur, &keys[i], &vals[i], i == 0 ? op : next);
+        if (rc != MDB_SUCCESS) break;
+    }
+    *count = i;
+    if (rc == MDB_NOTFOUND && i > 0) return MDB_SUCCESS;
+    return rc;
+}
+
+int lmdbgo_mdb_put_batch(MDB_txn *txn, MDB_dbi dbi, char *data, size_t *off, size_t n, unsigned int flags, size_t *count) {
+    MDB_val key, val;
+    int rc = MDB_SUCCESS;
+    size_t i;
+    for (i = 0; i < n; i++) {
+        LMDBGO_SET_VAL(&key, off[2*i+1] - off[2*i], data + off[2*i]);
+        LMDBGO_SET_VAL(&val, off[2*i+2] - off[2*i+1], data + off[2*i+1]);
+        rc = mdb_put(txn, dbi, &key, &val, flags);
+        if (rc != MDB_SUCCESS) break;
+    }
+    *count = i;
+    return rc;
+}
+
+int lmdbgo_mdb_cursor_put_batch(MDB_cursor *cur, char *data, size_t *off, size_t n, unsigned int flags, size_t *count) {
+    MDB_val key, val;
+    int rc = MDB_SUCCESS;
+    size_t i;
+    for (i = 0; i < n; i++) {
+        LMDBGO_SET_VAL(&key, off[2*i+1] - off[2*i], data + off[2*i]);
+        LMDBGO_SET_VAL(&val, off[2*i+2] - off[2*i+1], data + off[2*i+1]);
+        rc = mdb_cursor_put(cur, &key, &val, flags);
+        if (rc != MDB_SUCCESS) break;
+    }
+    *count = i;
+    return rc;
+}
+
+#define LMDBGO_INT_PROXIES(T, N) \
+    int lmdbgo_mdb_get_##N(MDB_txn *txn, MDB_dbi dbi, T k, MDB_val *val) { \
+        MDB_val key; \
+        LMDBGO_SET_VAL(&key, sizeof(k), &k); \
+        return mdb_get(txn, dbi, &key, val); \
+    } \
+    int lmdbgo_mdb_put_##N(MDB_txn *txn, MDB_dbi dbi, T k, char *vdata, size_t vn, unsigned int flags) { \
+        MDB_val key, val; \
+        LMDBGO_SET_VAL(&key, sizeof(k), &k); \
+        LMDBGO_SET_VAL(&val, vn, vdata); \
+        return mdb_put(txn, dbi, &key, &val, flags); \
+    } \
+    int lmdbgo_mdb_cursor_get_##N(MDB_cursor *cur, T k, MDB_val *key, MDB_val *val, MDB_cursor_op op) { \
+        int ret; \
+        LMDBGO_SET_VAL(key, sizeof(k), &k); \
+        ret = mdb_cursor_get(cur, key, val, op); \
+        if (ret == MDB_SUCCESS && key->mv_size != sizeof(k)) \
+            ret = MDB_BAD_VALSIZE; \
+        if (key->mv_data == &k) \
+            key->mv_data = 0; \
+        return ret; \
+    } \
+    int lmdbgo_mdb_cursor_put_##N(MDB_cursor *cur, T k, char *vdata, size_t vn, unsigned int flags) { \
+        MDB_val key, val; \
+        LMDBGO_SET_VAL(&key, sizeof(k), &k); \
+        LMDBGO_SET_VAL(&val, vn, vdata); \
+        return mdb_cursor_put(cur, &key, &val, flags); \
+    }
+LMDBGO_INT_PROXIES(uint64_t, uint64)
+LMDBGO_INT_PROXIES(uint32_t, uint32)
+
+static int lmdbgo_cmp_size(size_t an, size_t bn) {
+    return an < bn ? -1 : an > bn;
+}
+
+static int lmdbgo_cmp_memcmp(const MDB_val *a, const MDB_val *b) {
+    size_t n = a->mv_size < b->mv_size ? a->mv_size : b->mv_size;
+    int c = n ? memcmp(a->mv_data, b->mv_data, n) : 0;
+    return c ? c : lmdbgo_cmp_size(a->mv_size, b->mv_size);
+}
+
+static int lmdbgo_cmp_int64(const MDB_val *a, const MDB_val *b) {
+    int64_t x, y;
+    if (a->mv_size != sizeof(x) || b->mv_size != sizeof(y))
+        return lmdbgo_cmp_size(a->mv_size, b->mv_size);
+    // the data is not guaranteed to be aligned.
+    memcpy(&x, a->mv_data, sizeof(x));
+    memcpy(&y, b->mv_data, sizeof(y));
+    return x < y ? -1 : x > y;
+}
+
+static int lmdbgo_cmp_float64(const MDB_val *a, const MDB_val *b) {
+    double x, y;
+    int xnan, ynan;
+    if (a->mv_size != sizeof(x) || b->mv_size != sizeof(y))
+        return lmdbgo_cmp_size(a->mv_size, b->mv_size);
+    memcpy(&x, a->mv_data, sizeof(x));
+    memcpy(&y, b->mv_data, sizeof(y));
+    // NaN values sort after all other values so that the ordering is total.
+    xnan = x != x;
+    ynan = y != y;
+    if (xnan || ynan)
+        return xnan - ynan;
+    return x < y ? -1 : x > y;
+}
+
+static int lmdbgo_cmp_reverse(const MDB_val *a, const MDB_val *b) {
+    return lmdbgo_cmp_memcmp(b, a);
+}
+
+// lmdbgo_uvarint decodes a varint as written by Go's binary.PutUvarint.  The
+// number of bytes read is returned, or zero if the buffer is malformed.
+static size_t lmdbgo_uvarint(const unsigned char *p, size_t n, size_t *x) {
+    size_t i, v = 0;
+    unsigned int s = 0;
+    for (i = 0; i < n && s < 64; i++, s += 7) {
+        v |= (size_t)(p[i] & 0x7f) << s;
+        if (p[i] < 0x80) {
+            *x = v;
+            return i + 1;
+        }
+    }
+    return 0;
+}
+
+static int lmdbgo_cmp_lenprefix(const MDB_val *a, const MDB_val *b) {
+    const unsigned char *p = a->mv_data, *q = b->mv_data;
+    size_t pn = a->mv_size, qn = b->mv_size;
+    while (pn && qn) {
+        MDB_val x, y;
+        size_t xn = 0, yn = 0, i, j;
+        int c;
+        i = lmdbgo_uvarint(p, pn, &xn);
+        j = lmdbgo_uvarint(q, qn, &yn);
+        if (!i || !j || xn > pn - i || yn > qn - j)
+            break;
+        LMDBGO_SET_VAL(&x, xn, (void *)(p + i));
+        LMDBGO_SET_VAL(&y, yn, (void *)(q + j));
+        c = lmdbgo_cmp_memcmp(&x, &y);
+        if (c)
+            return c;
+        p += i + xn;
+        pn -= i + xn;
+        q += j + yn;
+        qn -= j + yn;
+    }
+    {
+        // malformed segments are compared bytewise.
+        MDB_val x, y;
+        LMDBGO_SET_VAL(&x, pn, (void *)p);
+        LMDBGO_SET_VAL(&y, qn, (void *)q);
+        return lmdbgo_cmp_memcmp(&x, &y);
+    }
+}
+
+MDB_cmp_func *lmdbgo_cmp_builtin(int id) {
+    switch (id) {
+    case LMDBGO_CMP_INT64:
+        return &lmdbgo_cmp_int64;
+    case LMDBGO_CMP_FLOAT64:
+        return &lmdbgo_cmp_float64;
+    case LMDBGO_CMP_REVERSE:
+        return &lmdbgo_cmp_reverse;
+    case LMDBGO_CMP_LENPREFIX:
+        return &lmdbgo_cmp_lenprefix;
+    }
+    return NULL;
+}
+
+#define LMDBGO_CMP_SLOT(n) \
+    static int lmdbgo_cmp_slot##n(const MDB_val *a, const MDB_val *b) { \
+        return lmdbgoMDBCmpBridge(n, (MDB_val *)a, (MDB_val *)b); \
+    }
+LMDBGO_CMP_SLOT(0)
+LMDBGO_CMP_SLOT(1)
+LMDBGO_CMP_SLOT(2)
+LMDBGO_CMP_SLOT(3)
+LMDBGO_CMP_SLOT(4)
+LMDBGO_CMP_SLOT(5)
+LMDBGO_CMP_SLOT(6)
+LMDBGO_CMP_SLOT(7)
+LMDBGO_CMP_SLOT(8)
+LMDBGO_CMP_SLOT(9)
+LMDBGO_CMP_SLOT(10)
+LMDBGO_CMP_SLOT(11)
+LMDBGO_CMP_SLOT(12)
+LMDBGO_CMP_SLOT(13)
+LMDBGO_CMP_SLOT(14)
+LMDBGO_CMP_SLOT(15)
+
+static MDB_cmp_func *lmdbgo_cmp_slots[LMDBGO_CMP_NSLOT] = {
+    &lmdbgo_cmp_slot0, &lmdbgo_cmp_slot1, &lmdbgo_cmp_slot2, &lmdbgo_cmp_slot3,
+    &lmdbgo_cmp_slot4, &lmdbgo_cmp_slot5, &lmdbgo_cmp_slot6, &lmdbgo_cmp_slot7,
+    &lmdbgo_cmp_slot8, &lmdbgo_cmp_slot9, &lmdbgo_cmp_slot10, &lmdbgo_cmp_slot11,
+    &lmdbgo_cmp_slot12, &lmdbgo_cmp_slot13, &lmdbgo_cmp_slot14, &lmdbgo_cmp_slot15,
+};
+
+MDB_cmp_func *lmdbgo_cmp_slot(size_t slot) {
+    if (slot >= LMDBGO_CMP_NSLOT)
+        return NULL;
+    return lmdbgo_cmp_slots[slot];
+}
@@ -5,6 +5,7 @@
 #ifndef _LMDBGO_H_
 #define _LMDBGO_H_
 
+#include <stdint.h>
 #include "lmdb.h"
 
 /* Proxy functions for lmdb get/put operations. The functions are defined to
@@ -27,6 +28,42 @@ int lmdbgo_mdb_cursor_putmulti(MDB_cursor *cur, char *kdata, size_t kn, char *vd
 int lmdbgo_mdb_cursor_get1(MDB_cursor *cur, char *kdata, size_t kn, MDB_val *key, MDB_val *val, MDB_cursor_op op);
 int lmdbgo_mdb_cursor_get2(MDB_cursor *cur, char *kdata, size_t kn, char *vdata, size_t vn, MDB_val *key, MDB_val *val, MDB_cursor_op op);
 
+/* lmdbgo_mdb_get_many and lmdbgo_mdb_cursor_get_many look up n keys packed
+ * in kdata, where key i occupies the bytes from koff[i] to koff[i+1].  The
+ * value and return code for key i are stored in vals[i] and rets[i].  Lookups
+ * stop at the first error other than MDB_NOTFOUND, which is returned.  The
+ * cursor variant uses MDB_SET which is efficient when keys are sorted.
+ * */
+int lmdbgo_mdb_get_many(MDB_txn *txn, MDB_dbi dbi, char *kdata, size_t *koff, size_t n, MDB_val *vals, int *rets);
+int lmdbgo_mdb_cursor_get_many(MDB_cursor *cur, char *kdata, size_t *koff, size_t n, MDB_val *vals, int *rets);
+
+/* lmdbgo_mdb_cursor_get_batch retrieves up to n items, the first using op and
+ * each subsequent item using next.  The number of items retrieved is stored
+ * in count.  MDB_NOTFOUND is only returned if no items were retrieved.
+ * */
+int lmdbgo_mdb_cursor_get_batch(MDB_cursor *cur, MDB_cursor_op op, MDB_cursor_op next, MDB_val *keys, MDB_val *vals, size_t n, size_t *count);
+
+/* lmdbgo_mdb_put_batch and lmdbgo_mdb_cursor_put_batch store n items packed
+ * in data, where the key of item i occupies the bytes from off[2*i] to
+ * off[2*i+1] and its value the bytes from off[2*i+1] to off[2*i+2].  The
+ * number of items stored before an error is encountered is stored in count.
+ * */
+int lmdbgo_mdb_put_batch(MDB_txn *txn, MDB_dbi dbi, char *data, size_t *off, size_t n, unsigned int flags, size_t *count);
+int lmdbgo_mdb_cursor_put_batch(MDB_cursor *cur, char *data, size_t *off, size_t n, unsigned int flags, size_t *count);
+
+/* Proxy functions for get/put operations using native integer keys (see
+ * MDB_INTEGERKEY).  The cursor get functions clear the mv_data field of key
+ * when the returned key is the argument k, which does not outlive the call.
+ * */
+int lmdbgo_mdb_get_uint64(MDB_txn *txn, MDB_dbi dbi, uint64_t k, MDB_val *val);
+int lmdbgo_mdb_get_uint32(MDB_txn *txn, MDB_dbi dbi, uint32_t k, MDB_val *val);
+int lmdbgo_mdb_put_uint64(MDB_txn *txn, MDB_dbi dbi, uint64_t k, char *vdata, size_t vn, unsigned int flags);
+int lmdbgo_mdb_put_uint32(MDB_txn *txn, MDB_dbi dbi, uint32_t k, char *vdata, size_t vn, unsigned int flags);
+int lmdbgo_mdb_cursor_get_uint64(MDB_cursor *cur, uint64_t k, MDB_val *key, MDB_val *val, MDB_cursor_op op);
+int lmdbgo_mdb_cursor_get_uint32(MDB_cursor *cur, uint32_t k, MDB_val *key, MDB_val *val, MDB_cursor_op op);
+int lmdbgo_mdb_cursor_put_uint64(MDB_cursor *cur, uint64_t k, char *vdata, size_t vn, unsigned int flags);
+int lmdbgo_mdb_cursor_put_uint32(MDB_cursor *cur, uint32_t k, char *vdata, size_t vn, unsigned int flags);
+
 /* ConstCString wraps a null-terminated (const char *) because Go's type system
  * does not represent the 'cosnt' qualifier directly on a function argument and
  * causes warnings to be emitted during linking.
@@ -39,4 +76,24 @@ typedef struct{ const char *p; } lmdbgo_ConstCString;
  * */
 int lmdbgo_mdb_reader_list(MDB_env *env, size_t ctx);
 
+/* Builtin comparison functions which may be passed to mdb_set_compare and
+ * mdb_set_dupsort.  The function returned for an unknown identifier is NULL.
+ * */
+#define LMDBGO_CMP_INT64 1
+#define LMDBGO_CMP_FLOAT64 2
+#define LMDBGO_CMP_REVERSE 3
+#define LMDBGO_CMP_LENPREFIX 4
+MDB_cmp_func *lmdbgo_cmp_builtin(int id);
+
+/* lmdbgo_cmp_slot returns a static comparison function that relays
+ * comparisons over the lmdbgoMDBCmpBridge external Go func, passing slot as
+ * context so the bridge can dispatch to a registered Go function.  NULL is
+ * returned if slot is not less than LMDBGO_CMP_NSLOT.
+ *
+ * A fixed number of slots is required because MDB_cmp_func has no context
+ * argument.
+ * */
+#define LMDBGO_CMP_NSLOT 16
+MDB_cmp_func *lmdbgo_cmp_slot(size_t slot);
+
 #endif
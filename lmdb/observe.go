@@ -0,0 +1,200 @@
+package lmdb
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// ChangeOp identifies the kind of modification described by a Change.
+type ChangeOp int
+
+// The kinds of modification described by a Change.
+const (
+	ChangePut  ChangeOp = iota // A key was stored.
+	ChangeDel                  // A key (or one of its duplicates) was deleted.
+	ChangeDrop                 // A database was emptied or deleted.
+)
+
+// Change describes a modification to a database made by a transaction.
+type Change struct {
+	DBI DBI
+	Op  ChangeOp
+	Key []byte // nil if Op is ChangeDrop
+}
+
+// ChangeSet holds the changes made by a committed transaction which match the
+// filter of an Observer.  Changes are listed in the order they were made and
+// a key may appear more than once.
+type ChangeSet struct {
+	TxnID   uintptr
+	Changes []Change
+}
+
+// Observer receives the changes made by committed update transactions in an
+// Env.  Observers are created with Env.Observe.
+type Observer struct {
+	env    *Env
+	dbi    DBI
+	prefix []byte
+	fn     func(*ChangeSet)
+
+	// mut is held while fn is called so that Close can wait for calls in
+	// progress.
+	mut    sync.Mutex
+	closed bool
+}
+
+// observers is the set of Observers registered with an Env.
+type observers struct {
+	n    int32 // accessed atomically
+	mut  sync.RWMutex
+	list []*Observer
+}
+
+// Observe registers fn to be called after each update transaction in env that
+// modifies a key in dbi beginning with prefix is committed.  The ChangeSet
+// passed to fn contains only matching changes, and includes any call to
+// Txn.Drop for dbi.  An empty prefix matches all keys.  Changes made by
+// subtransactions are only reported if they are committed along with their
+// parent.
+//
+// Transactions record their changes only while env has observers so there is
+// no overhead when Observe is not used.  A transaction begun before an
+// observer is registered does not report its changes to that observer.
+//
+// The function fn is called by the goroutine which committed the
+// transaction, after the transaction has committed, so it should return
+// quickly and must not retain the ChangeSet.  The keys in a ChangeSet may be
+// retained.  Calls to fn are not concurrent.  The Close method must be called
+// when the Observer is no longer needed.
+func (env *Env) Observe(dbi DBI, prefix []byte, fn func(*ChangeSet)) *Observer {
+	o := &Observer{
+		env:    env,
+		dbi:    dbi,
+		prefix: append([]byte(nil), prefix...),
+		fn:     fn,
+	}
+	env.observers.mut.Lock()
+	env.observers.list = append(env.observers.list, o)
+	atomic.StoreInt32(&env.observers.n, int32(len(env.observers.list)))
+	env.observers.mut.Unlock()
+	return o
+}
+
+// Close unregisters o.  Close waits for a call to the function of o in
+// progress to return, so after Close returns o will receive no further
+// changes.  Close must not be called by the function of o, which would
+// deadlock.
+func (o *Observer) Close() {
+	obs := &o.env.observers
+	obs.mut.Lock()
+	for i := range obs.list {
+		if obs.list[i] == o {
+			list := make([]*Observer, 0, len(obs.list)-1)
+			list = append(list, obs.list[:i]...)
+			obs.list = append(list, obs.list[i+1:]...)
+			break
+		}
+	}
+	atomic.StoreInt32(&obs.n, int32(len(obs.list)))
+	obs.mut.Unlock()
+
+	o.mut.Lock()
+	o.closed = true
+	o.mut.Unlock()
+}
+
+// deliver calls the function of o with cs unless o has been closed.
+func (o *Observer) deliver(cs *ChangeSet) {
+	o.mut.Lock()
+	defer o.mut.Unlock()
+	if !o.closed {
+		o.fn(cs)
+	}
+}
+
+func (o *Observer) match(c *Change) bool {
+	if c.DBI != o.dbi {
+		return false
+	}
+	return c.Op == ChangeDrop || bytes.HasPrefix(c.Key, o.prefix)
+}
+
+// observing returns true if env has any registered observers.
+func (env *Env) observing() bool {
+	return atomic.LoadInt32(&env.observers.n) > 0
+}
+
+// notify delivers the changes made by the transaction with the given id to
+// the observers of env.
+func (env *Env) notify(id uintptr, changes []Change) {
+	if len(changes) == 0 {
+		return
+	}
+	env.observers.mut.RLock()
+	list := env.observers.list
+	env.observers.mut.RUnlock()
+
+	for _, o := range list {
+		var matched []Change
+		for i := range changes {
+			if o.match(&changes[i]) {
+				matched = append(matched, changes[i])
+			}
+		}
+		if len(matched) > 0 {
+			o.deliver(&ChangeSet{TxnID: id, Changes: matched})
+		}
+	}
+}
+
+// changeLog records the changes made by a Txn while its Env has observers.
+type changeLog struct {
+	changes []Change
+}
+
+func (txn *Txn) record(dbi DBI, op ChangeOp, key []byte) {
+	txn.recordOwned(dbi, op, append([]byte(nil), key...))
+}
+
+// recordOwned is like record but key must not be modified by the caller.
+func (txn *Txn) recordOwned(dbi DBI, op ChangeOp, key []byte) {
+	txn.changes.changes = append(txn.changes.changes, Change{
+		DBI: dbi,
+		Op:  op,
+		Key: key,
+	})
+}
+
+func (txn *Txn) recordUint64(dbi DBI, k uint64) {
+	txn.record(dbi, ChangePut, (*[8]byte)(unsafe.Pointer(&k))[:])
+}
+
+func (txn *Txn) recordUint32(dbi DBI, k uint32) {
+	txn.record(dbi, ChangePut, (*[4]byte)(unsafe.Pointer(&k))[:])
+}
+
+func (txn *Txn) recordBatch(dbi DBI, b *PutBuffer, n int) {
+	for i := 0; i < n; i++ {
+		txn.record(dbi, ChangePut, b.Key(i))
+	}
+}
+
+// finishChanges delivers the changes recorded by txn to observers if txn was
+// committed, or transfers them to the parent of a subtransaction.
+func (txn *Txn) finishChanges(committed bool, id uintptr, parent *Txn) {
+	log := txn.changes
+	txn.changes = nil
+	if log == nil || !committed {
+		return
+	}
+	if parent != nil {
+		if parent.changes != nil {
+			parent.changes.changes = append(parent.changes.changes, log.changes...)
+		}
+		return
+	}
+	txn.env.notify(id, log.changes)
+}
@@ -0,0 +1,288 @@
+package lmdb
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEnv_Observe(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi1, err := openDBI(env, "db1", Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbi2, err := openDBI(env, "db2", Create|DupSort|DupFixed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sets []*ChangeSet
+	obs := env.Observe(dbi1, []byte("a"), func(cs *ChangeSet) {
+		sets = append(sets, cs)
+	})
+	var all []*ChangeSet
+	obs2 := env.Observe(dbi2, nil, func(cs *ChangeSet) {
+		all = append(all, cs)
+	})
+	defer obs2.Close()
+
+	var id uintptr
+	err = env.Update(func(txn *Txn) (err error) {
+		id = txn.ID()
+		err = txn.Put(dbi1, []byte("a1"), []byte("v"), 0)
+		if err != nil {
+			return err
+		}
+		err = txn.Put(dbi1, []byte("b1"), []byte("v"), 0)
+		if err != nil {
+			return err
+		}
+		err = txn.Sub(func(txn *Txn) error {
+			return txn.Put(dbi1, []byte("a2"), []byte("v"), 0)
+		})
+		if err != nil {
+			return err
+		}
+		err = txn.Sub(func(txn *Txn) error {
+			err := txn.Put(dbi1, []byte("a3"), []byte("v"), 0)
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("abort")
+		})
+		if err == nil {
+			return fmt.Errorf("expected an error")
+		}
+
+		cur, err := txn.OpenCursor(dbi1)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+		err = cur.Put([]byte("a4"), []byte("v"), 0)
+		if err != nil {
+			return err
+		}
+		_, err = cur.PutReserve([]byte("a5"), 1, 0)
+		if err != nil {
+			return err
+		}
+		_, _, err = cur.Get([]byte("a1"), nil, Set)
+		if err != nil {
+			return err
+		}
+		err = cur.Del(0)
+		if err != nil {
+			return err
+		}
+
+		cur2, err := txn.OpenCursor(dbi2)
+		if err != nil {
+			return err
+		}
+		defer cur2.Close()
+		return cur2.PutMulti([]byte("m"), []byte("xxyy"), 2, 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []*ChangeSet{{
+		TxnID: id,
+		Changes: []Change{
+			{dbi1, ChangePut, []byte("a1")},
+			{dbi1, ChangePut, []byte("a2")},
+			{dbi1, ChangePut, []byte("a4")},
+			{dbi1, ChangePut, []byte("a5")},
+			{dbi1, ChangeDel, []byte("a1")},
+		},
+	}}
+	if !reflect.DeepEqual(sets, exp) {
+		t.Errorf("unexpected change sets: %v (!= %v)", sets, exp)
+	}
+	if len(all) != 1 || !reflect.DeepEqual(all[0].Changes, []Change{{dbi2, ChangePut, []byte("m")}}) {
+		t.Errorf("unexpected change sets: %v", all)
+	}
+
+	// aborted transactions and transactions without matching changes are not
+	// reported.
+	sets = nil
+	err = env.Update(func(txn *Txn) (err error) {
+		return txn.Put(dbi1, []byte("b2"), []byte("v"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.Update(func(txn *Txn) (err error) {
+		err = txn.Put(dbi1, []byte("a6"), []byte("v"), 0)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("abort")
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	err = env.Update(func(txn *Txn) (err error) {
+		return txn.Drop(dbi1, false)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sets) != 1 || !reflect.DeepEqual(sets[0].Changes, []Change{{dbi1, ChangeDrop, nil}}) {
+		t.Errorf("unexpected change sets: %v", sets)
+	}
+
+	sets = nil
+	obs.Close()
+	err = env.Update(func(txn *Txn) (err error) {
+		return txn.Put(dbi1, []byte("a7"), []byte("v"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sets) != 0 {
+		t.Errorf("unexpected change sets: %v", sets)
+	}
+}
+
+func TestEnv_Observe_none(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	obs := env.Observe(0, nil, func(*ChangeSet) {})
+	obs.Close()
+
+	err := env.Update(func(txn *Txn) (err error) {
+		if txn.changes != nil {
+			t.Errorf("changes recorded without observers")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEnv_Observe_rawRead(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openDBI(env, "db", Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for i := 0; i < 10; i++ {
+		keys = append(keys, fmt.Sprintf("key%d", i))
+	}
+	err = env.Update(func(txn *Txn) (err error) {
+		for _, k := range keys {
+			err = txn.Put(dbi, []byte(k), []byte("v"), 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var deleted []string
+	obs := env.Observe(dbi, nil, func(cs *ChangeSet) {
+		for _, c := range cs.Changes {
+			deleted = append(deleted, string(c.Key))
+		}
+	})
+	defer obs.Close()
+
+	// Deleting an item rewrites the page to which a raw key refers.
+	err = env.Update(func(txn *Txn) (err error) {
+		txn.RawRead = true
+		cur, err := txn.OpenCursor(dbi)
+		if err != nil {
+			return err
+		}
+		defer cur.Close()
+		for _, _, err = cur.Get(nil, nil, First); err == nil; _, _, err = cur.Get(nil, nil, Next) {
+			err = cur.Del(0)
+			if err != nil {
+				return err
+			}
+		}
+		if IsNotFound(err) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(deleted, keys) {
+		t.Errorf("unexpected keys: %q (!= %q)", deleted, keys)
+	}
+}
+
+func TestObserver_Close(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openDBI(env, "db", Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	called := make(chan struct{})
+	release := make(chan struct{})
+	var n int
+	var mut sync.Mutex
+	obs := env.Observe(dbi, nil, func(*ChangeSet) {
+		mut.Lock()
+		n++
+		mut.Unlock()
+		close(called)
+		<-release
+	})
+
+	go func() {
+		err := env.Update(func(txn *Txn) (err error) {
+			return txn.Put(dbi, []byte("k"), []byte("v"), 0)
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+	<-called
+
+	// Close does not return while a notification is in progress.
+	closed := make(chan struct{})
+	go func() {
+		obs.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close returned during a notification")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	<-closed
+
+	err = env.Update(func(txn *Txn) (err error) {
+		return txn.Put(dbi, []byte("k"), []byte("v"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mut.Lock()
+	defer mut.Unlock()
+	if n != 1 {
+		t.Errorf("unexpected notifications: %d (!= 1)", n)
+	}
+}
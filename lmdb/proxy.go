@@ -0,0 +1,355 @@
+package lmdb
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+var errProxyTxnDone = errors.New("transaction has terminated")
+var errProxyCursorClosed = errors.New("cursor is closed")
+var errProxyEnvClosed = errors.New("environment is closed")
+
+// proxyThread executes functions on a goroutine which is locked to its
+// thread.
+type proxyThread struct {
+	mut     sync.Mutex
+	fns     chan func()
+	stopped bool
+}
+
+func newProxyThread() *proxyThread {
+	t := &proxyThread{fns: make(chan func())}
+	go t.loop()
+	return t
+}
+
+func (t *proxyThread) loop() {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	for fn := range t.fns {
+		fn()
+	}
+}
+
+// send passes fn to the goroutine executing functions for t.  An error is
+// returned if t has been stopped.
+func (t *proxyThread) send(fn func()) error {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	if t.stopped {
+		return errProxyEnvClosed
+	}
+	t.fns <- fn
+	return nil
+}
+
+// stop terminates the goroutine executing functions for t.  Functions sent
+// to t after stop is called are not executed.
+func (t *proxyThread) stop() {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	if !t.stopped {
+		t.stopped = true
+		close(t.fns)
+	}
+}
+
+// ProxyTxn is a write transaction which may be used from any goroutine.  The
+// underlying Txn is owned by a goroutine locked to its thread, which is
+// allocated by the Env when the first ProxyTxn is begun, and every operation
+// on a ProxyTxn is sent to that goroutine to be executed.  A ProxyTxn
+// removes the need to call runtime.LockOSThread in application code at the
+// cost of a goroutine handoff for each operation.
+//
+// The methods of a ProxyTxn are safe to call concurrently from multiple
+// goroutines and are executed one at a time.
+//
+// Only one ProxyTxn may be active in an Env at a time.  BeginProxyTxn blocks
+// until any active ProxyTxn has terminated.  A ProxyTxn must be terminated
+// by calling Commit or Abort, otherwise every later call to BeginProxyTxn
+// blocks until the ProxyTxn becomes unreachable and is aborted by a
+// finalizer, which logs it to standard error like an unreachable Txn.
+//
+// The Env must not be closed while a ProxyTxn is active.  If it is, the
+// operations of the ProxyTxn return an error instead of executing.
+type ProxyTxn struct {
+	env    *Env
+	thread *proxyThread
+	mut    sync.Mutex
+	txn    *Txn
+	done   chan struct{}
+}
+
+// BeginProxyTxn begins a write transaction which executes on a thread owned
+// by env.  Flags are passed to mdb_txn_begin as they are by BeginTxn.
+//
+// See mdb_txn_begin.
+func (env *Env) BeginProxyTxn(flags uint) (*ProxyTxn, error) {
+	env.proxyLock.Lock()
+	thread, err := env.proxyThread()
+	if err != nil {
+		env.proxyLock.Unlock()
+		return nil, err
+	}
+	p := &ProxyTxn{
+		env:    env,
+		thread: thread,
+		done:   make(chan struct{}, 1),
+	}
+	err = p.do(func() {
+		p.txn, err = beginTxn(env, nil, flags)
+	})
+	if err != nil {
+		env.proxyLock.Unlock()
+		return nil, err
+	}
+	runtime.SetFinalizer(p, (*ProxyTxn).finalize)
+	return p, nil
+}
+
+// proxyThread returns the thread executing the operations of ProxyTxns in
+// env, allocating it if necessary.
+func (env *Env) proxyThread() (*proxyThread, error) {
+	env.proxyMut.Lock()
+	defer env.proxyMut.Unlock()
+	if env.proxyClosed {
+		return nil, errProxyEnvClosed
+	}
+	if env.proxy == nil {
+		env.proxy = newProxyThread()
+	}
+	return env.proxy, nil
+}
+
+// stopProxy stops the thread executing the operations of ProxyTxns in env.
+// Operations of a ProxyTxn which is still active return an error.
+func (env *Env) stopProxy() {
+	env.proxyMut.Lock()
+	defer env.proxyMut.Unlock()
+	env.proxyClosed = true
+	if env.proxy != nil {
+		env.proxy.stop()
+		env.proxy = nil
+	}
+}
+
+// do executes fn on the thread owned by p.thread and waits for it to return.
+// If fn panics the panic is propagated to the caller of do.  An error is
+// returned if the Env has been closed.
+func (p *ProxyTxn) do(fn func()) error {
+	var panicked bool
+	var panicv interface{}
+	err := p.thread.send(func() {
+		defer func() {
+			if e := recover(); e != nil {
+				panicked = true
+				panicv = e
+			}
+			p.done <- struct{}{}
+		}()
+		fn()
+	})
+	if err != nil {
+		return err
+	}
+	<-p.done
+	if panicked {
+		panic(panicv)
+	}
+	return nil
+}
+
+// run calls fn with the underlying Txn on its thread.  An error is returned
+// if p has terminated.
+func (p *ProxyTxn) run(fn func(txn *Txn) error) (err error) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	if p.txn == nil {
+		return errProxyTxnDone
+	}
+	derr := p.do(func() {
+		err = fn(p.txn)
+	})
+	if derr != nil {
+		return derr
+	}
+	return err
+}
+
+// Do calls fn with the Txn underlying p on the thread which owns it.  Do
+// allows any Txn method to be used through a ProxyTxn.  The Txn passed to fn
+// must not be retained or used by any other goroutine, and fn must not
+// terminate it.  Values read by fn with RawRead set to true must not be
+// retained after fn returns.
+//
+// The methods of p and its cursors must not be called by fn.  They wait for
+// fn to return, so calling them from fn deadlocks.
+func (p *ProxyTxn) Do(fn TxnOp) error {
+	return p.run(func(txn *Txn) error {
+		txn.managed = true
+		defer func(raw bool) {
+			txn.managed = false
+			txn.RawRead = raw
+		}(txn.RawRead)
+		return fn(txn)
+	})
+}
+
+// OpenDBI opens a named database in the environment.  See Txn.OpenDBI.
+func (p *ProxyTxn) OpenDBI(name string, flags uint) (dbi DBI, err error) {
+	err = p.run(func(txn *Txn) (err error) {
+		dbi, err = txn.OpenDBI(name, flags)
+		return err
+	})
+	return dbi, err
+}
+
+// OpenRoot opens the root database.  See Txn.OpenRoot.
+func (p *ProxyTxn) OpenRoot(flags uint) (dbi DBI, err error) {
+	err = p.run(func(txn *Txn) (err error) {
+		dbi, err = txn.OpenRoot(flags)
+		return err
+	})
+	return dbi, err
+}
+
+// Get retrieves items from database dbi.  The returned slice is always a copy
+// of the stored value.  See Txn.Get.
+func (p *ProxyTxn) Get(dbi DBI, key []byte) (val []byte, err error) {
+	err = p.run(func(txn *Txn) (err error) {
+		val, err = txn.Get(dbi, key)
+		return err
+	})
+	return val, err
+}
+
+// Put stores an item in database dbi.  See Txn.Put.
+func (p *ProxyTxn) Put(dbi DBI, key, val []byte, flags uint) error {
+	return p.run(func(txn *Txn) error {
+		return txn.Put(dbi, key, val, flags)
+	})
+}
+
+// Del deletes an item from database dbi.  See Txn.Del.
+func (p *ProxyTxn) Del(dbi DBI, key, val []byte) error {
+	return p.run(func(txn *Txn) error {
+		return txn.Del(dbi, key, val)
+	})
+}
+
+// OpenCursor allocates and initializes a ProxyCursor for database dbi.  See
+// Txn.OpenCursor.
+func (p *ProxyTxn) OpenCursor(dbi DBI) (*ProxyCursor, error) {
+	var cur *Cursor
+	err := p.run(func(txn *Txn) (err error) {
+		cur, err = txn.OpenCursor(dbi)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ProxyCursor{p: p, cur: cur}, nil
+}
+
+// Commit commits all operations of the transaction into the database.  The
+// ProxyTxn cannot be used again after Commit is called.  See Txn.Commit.
+func (p *ProxyTxn) Commit() error {
+	return p.terminate(func(txn *Txn) error {
+		return txn.Commit()
+	})
+}
+
+// Abort discards pending writes in the transaction.  The ProxyTxn cannot be
+// used again after Abort is called, and calling Abort after the transaction
+// has terminated has no effect.  See Txn.Abort.
+func (p *ProxyTxn) Abort() {
+	p.terminate(func(txn *Txn) error {
+		txn.Abort()
+		return nil
+	})
+}
+
+// terminate calls fn to terminate the underlying Txn and allows another
+// ProxyTxn to begin in p.env.  If the Env has been closed the Txn cannot be
+// terminated, but another ProxyTxn is still allowed to begin.
+func (p *ProxyTxn) terminate(fn func(txn *Txn) error) (err error) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	if p.txn == nil {
+		return nil
+	}
+	defer func() {
+		p.txn = nil
+		runtime.SetFinalizer(p, nil)
+		p.env.proxyLock.Unlock()
+	}()
+	derr := p.do(func() {
+		err = fn(p.txn)
+	})
+	if derr != nil {
+		return derr
+	}
+	return err
+}
+
+// finalize aborts p if it is unreachable and was not terminated.
+func (p *ProxyTxn) finalize() {
+	if p.txn != nil {
+		p.txn.errf("lmdb: aborting unreachable proxy transaction %#x", uintptr(unsafe.Pointer(p)))
+		p.Abort()
+	}
+}
+
+// ProxyCursor is a cursor opened in a ProxyTxn.  Like the ProxyTxn itself
+// the methods of a ProxyCursor may be called from any goroutine.  A
+// ProxyCursor must be closed before its transaction terminates.
+type ProxyCursor struct {
+	p   *ProxyTxn
+	cur *Cursor
+}
+
+// run calls fn with the underlying Cursor on the thread owning its
+// transaction.
+func (c *ProxyCursor) run(fn func(cur *Cursor) error) error {
+	return c.p.run(func(txn *Txn) error {
+		if c.cur == nil {
+			return errProxyCursorClosed
+		}
+		return fn(c.cur)
+	})
+}
+
+// Get retrieves items from the database.  The returned slices are always
+// copies of stored data.  See Cursor.Get.
+func (c *ProxyCursor) Get(setkey, setval []byte, op uint) (key, val []byte, err error) {
+	err = c.run(func(cur *Cursor) (err error) {
+		key, val, err = cur.Get(setkey, setval, op)
+		return err
+	})
+	return key, val, err
+}
+
+// Put stores an item in the database.  See Cursor.Put.
+func (c *ProxyCursor) Put(key, val []byte, flags uint) error {
+	return c.run(func(cur *Cursor) error {
+		return cur.Put(key, val, flags)
+	})
+}
+
+// Del deletes the item referred to by the cursor.  See Cursor.Del.
+func (c *ProxyCursor) Del(flags uint) error {
+	return c.run(func(cur *Cursor) error {
+		return cur.Del(flags)
+	})
+}
+
+// Close closes the cursor.  See Cursor.Close.
+func (c *ProxyCursor) Close() {
+	c.run(func(cur *Cursor) error {
+		cur.Close()
+		c.cur = nil
+		return nil
+	})
+}
@@ -0,0 +1,231 @@
+package lmdb
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProxyTxn(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	p, err := env.BeginProxyTxn(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbi, err := p.OpenDBI("testdb", Create)
+	if err != nil {
+		p.Abort()
+		t.Fatal(err)
+	}
+
+	// operations are issued from goroutines which are not locked to a thread.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			k := []byte(fmt.Sprintf("k%d", i))
+			err := p.Put(dbi, k, k, 0)
+			if err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	v, err := p.Get(dbi, []byte("k3"))
+	if err != nil {
+		t.Error(err)
+	} else if string(v) != "k3" {
+		t.Errorf("unexpected value: %q (!= %q)", v, "k3")
+	}
+	err = p.Del(dbi, []byte("k3"), nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	cur, err := p.OpenCursor(dbi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	for op := uint(First); ; op = Next {
+		_, _, err := cur.Get(nil, nil, op)
+		if IsNotFound(err) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	cur.Close()
+	if n != 9 {
+		t.Errorf("unexpected number of items: %d (!= 9)", n)
+	}
+
+	err = p.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Put(dbi, []byte("k"), []byte("v"), 0)
+	if err != errProxyTxnDone {
+		t.Errorf("unexpected error: %v (!= %v)", err, errProxyTxnDone)
+	}
+	p.Abort()
+
+	err = env.View(func(txn *Txn) (err error) {
+		_, err = txn.Get(dbi, []byte("k9"))
+		return err
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProxyTxn_Abort(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openDBI(env, "testdb", Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ProxyTxns are serialized so the second transaction cannot begin until
+	// the first has been aborted.
+	for i := 0; i < 2; i++ {
+		p, err := env.BeginProxyTxn(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = p.Put(dbi, []byte("k"), []byte("v"), 0)
+		if err != nil {
+			t.Error(err)
+		}
+		p.Abort()
+	}
+
+	err = env.View(func(txn *Txn) (err error) {
+		_, err = txn.Get(dbi, []byte("k"))
+		return err
+	})
+	if !IsNotFound(err) {
+		t.Errorf("expected NotFound: %v", err)
+	}
+}
+
+func TestProxyTxn_Do(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	p, err := env.BeginProxyTxn(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Abort()
+
+	var dbi DBI
+	err = p.Do(func(txn *Txn) (err error) {
+		dbi, err = txn.OpenDBI("testdb", Create)
+		if err != nil {
+			return err
+		}
+		return txn.Sub(func(txn *Txn) error {
+			return txn.Put(dbi, []byte("k"), []byte("v"), 0)
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	func() {
+		defer func() {
+			if e := recover(); e == nil {
+				t.Errorf("expected a panic")
+			}
+		}()
+		p.Do(func(txn *Txn) error {
+			return txn.Commit()
+		})
+	}()
+
+	v, err := p.Get(dbi, []byte("k"))
+	if err != nil {
+		t.Error(err)
+	} else if string(v) != "v" {
+		t.Errorf("unexpected value: %q (!= %q)", v, "v")
+	}
+}
+
+func TestProxyTxn_envClosed(t *testing.T) {
+	env := setup(t)
+	path, err := env.Path()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+
+	dbi, err := openDBI(env, "testdb", Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := env.BeginProxyTxn(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Closing the environment with an active ProxyTxn is an application
+	// error, but operations must fail instead of panicking.
+	env.Close()
+	err = p.Put(dbi, []byte("k"), []byte("v"), 0)
+	if err != errProxyEnvClosed {
+		t.Errorf("unexpected error: %v (!= %v)", err, errProxyEnvClosed)
+	}
+	p.Abort()
+	_, err = env.BeginProxyTxn(0)
+	if err != errProxyEnvClosed {
+		t.Errorf("unexpected error: %v (!= %v)", err, errProxyEnvClosed)
+	}
+}
+
+func TestProxyTxn_finalize(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	func() {
+		_, err := env.BeginProxyTxn(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// The unreachable ProxyTxn is aborted so another may begin.
+	done := make(chan error, 1)
+	go func() {
+		p, err := env.BeginProxyTxn(0)
+		if err == nil {
+			p.Abort()
+		}
+		done <- err
+	}()
+	timeout := time.After(5 * time.Second)
+	for {
+		runtime.GC()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Error(err)
+			}
+			return
+		case <-timeout:
+			t.Fatal("unreachable ProxyTxn was not aborted")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package lmdb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ReaderInfo describes a slot in the reader lock table of an environment.
+type ReaderInfo struct {
+	PID      int     // Process holding the slot
+	ThreadID uintptr // Thread which last began a transaction using the slot
+	TxnID    int64   // Snapshot viewed by the reader, or -1 if it is idle
+	Stale    bool    // The process holding the slot no longer exists
+}
+
+// Active returns true if r holds a snapshot of the environment.  Only active
+// readers prevent pages from being reused.
+func (r *ReaderInfo) Active() bool {
+	return r.TxnID >= 0
+}
+
+// Lag returns the number of transactions committed since the snapshot viewed
+// by r, given the ID of the last committed transaction (see EnvInfo).  Lag
+// returns zero if r is not active.
+func (r *ReaderInfo) Lag(lastTxnID int64) int64 {
+	if !r.Active() || r.TxnID > lastTxnID {
+		return 0
+	}
+	return lastTxnID - r.TxnID
+}
+
+// Readers returns the slots in use in the reader lock table.  Stale readers
+// are detected by checking whether their process is running, in the same
+// manner as ReaderCheck, but are not cleared.  The slots are parsed from the
+// text written by mdb_reader_list, and an error is returned if it is not in
+// the expected format.
+//
+// See mdb_reader_list.
+func (env *Env) Readers() ([]ReaderInfo, error) {
+	var readers []ReaderInfo
+	err := env.ReaderList(func(line string) error {
+		r, ok, err := parseReader(line)
+		if ok {
+			readers = append(readers, r)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range readers {
+		readers[i].Stale = !pidAlive(readers[i].PID)
+	}
+	return readers, nil
+}
+
+// MaxReaderLag returns the largest number of transactions committed since
+// the snapshot viewed by any active reader in the environment.  A large lag
+// typically indicates a long-running or leaked read transaction, which keeps
+// the pages it references from being reused.
+func (env *Env) MaxReaderLag() (int64, error) {
+	readers, err := env.Readers()
+	if err != nil {
+		return 0, err
+	}
+	info, err := env.Info()
+	if err != nil {
+		return 0, err
+	}
+	var max int64
+	for i := range readers {
+		lag := readers[i].Lag(info.LastTxnID)
+		if lag > max {
+			max = lag
+		}
+	}
+	return max, nil
+}
+
+// parseReader parses a line written by mdb_reader_list.  The returned bool is
+// false if line does not describe a reader.
+func parseReader(line string) (r ReaderInfo, ok bool, err error) {
+	// mdb_reader_list writes a header followed by one line per reader, or a
+	// single line if there are no readers.  Any other line is an error so
+	// that a change in the format is not silently ignored.
+	fields := strings.Fields(line)
+	switch strings.Join(fields, " ") {
+	case "", "pid thread txnid", "(no active readers)":
+		return r, false, nil
+	}
+	if len(fields) != 3 {
+		return r, false, fmt.Errorf("invalid reader: %q", line)
+	}
+	r.PID, err = strconv.Atoi(fields[0])
+	if err != nil {
+		return r, false, fmt.Errorf("invalid reader pid: %q", line)
+	}
+	tid, err := strconv.ParseUint(fields[1], 16, 64)
+	if err != nil {
+		return r, false, fmt.Errorf("invalid reader thread: %q", line)
+	}
+	r.ThreadID = uintptr(tid)
+	if fields[2] == "-" {
+		r.TxnID = -1
+	} else {
+		r.TxnID, err = strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return r, false, fmt.Errorf("invalid reader txnid: %q", line)
+		}
+	}
+	return r, true, nil
+}
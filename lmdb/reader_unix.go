@@ -0,0 +1,11 @@
+// +build !windows
+
+package lmdb
+
+import "syscall"
+
+// pidAlive returns true if a process with the given pid exists.
+func pidAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,17 @@
+package lmdb
+
+import "syscall"
+
+// pidAlive returns true if a process with the given pid exists.
+func pidAlive(pid int) bool {
+	const processQueryLimitedInformation = 0x1000
+	const stillActive = 259
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return err == syscall.ERROR_ACCESS_DENIED
+	}
+	defer syscall.CloseHandle(h)
+	var code uint32
+	err = syscall.GetExitCodeProcess(h, &code)
+	return err != nil || code == stillActive
+}
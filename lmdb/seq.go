@@ -0,0 +1,275 @@
+package lmdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+)
+
+// SequenceDB is the name of the database in which NextSequence stores the
+// sequence of each database.  The database is created in the root database
+// the first time NextSequence is called, so applications using sequences
+// must reserve one named database for it with Env.SetMaxDBs.
+const SequenceDB = "lmdb-go.sequence"
+
+// rootSeqKey is the key of the root database's sequence in SequenceDB.  LMDB
+// does not allow empty keys and database names cannot contain null bytes, so
+// it cannot collide with a named database.
+const rootSeqKey = "\x00"
+
+var (
+	errUnknownDBI   = errors.New("database name is not known")
+	errBatchSize    = errors.New("sequence batch size must be positive")
+	errSeqReadonly  = errors.New("sequence cannot be changed in a readonly transaction")
+	errSeqCorrupted = errors.New("sequence is not 8 bytes")
+)
+
+// dbiNames maps database handles to the names they were opened with.
+type dbiNames struct {
+	mut sync.RWMutex
+	m   map[DBI]string
+}
+
+func (n *dbiNames) set(dbi DBI, name string) {
+	n.mut.Lock()
+	if n.m == nil {
+		n.m = make(map[DBI]string)
+	}
+	n.m[dbi] = name
+	n.mut.Unlock()
+}
+
+func (n *dbiNames) del(dbi DBI) {
+	n.mut.Lock()
+	delete(n.m, dbi)
+	n.mut.Unlock()
+}
+
+// key returns the key of the sequence of dbi in SequenceDB.
+func (n *dbiNames) key(dbi DBI) ([]byte, error) {
+	n.mut.RLock()
+	name, ok := n.m[dbi]
+	n.mut.RUnlock()
+	if !ok && dbi == mainDBI {
+		ok = true
+	}
+	if !ok {
+		return nil, errUnknownDBI
+	}
+	if name == "" {
+		return []byte(rootSeqKey), nil
+	}
+	return []byte(name), nil
+}
+
+// seqRange is a range of sequence numbers (last, end] which have been stored
+// in SequenceDB but not yet returned by NextSequence.
+type seqRange struct {
+	last uint64
+	end  uint64
+}
+
+// seqCache holds the unused sequence numbers reserved by committed
+// transactions.  Ranges modified by a transaction are held by the transaction
+// and only added to the cache when it commits, so other transactions never
+// observe numbers reserved or used by a transaction which may still abort.
+// Update transactions are serialized by LMDB, so the lock is only held for
+// the duration of each call.
+type seqCache struct {
+	mut   sync.Mutex
+	batch uint64
+	m     map[string]seqRange
+}
+
+// SetSequenceBatch sets the number of sequence numbers reserved each time
+// NextSequence writes to SequenceDB.  Reserved numbers are cached by the Env
+// and returned by NextSequence in later transactions without writing to the
+// database.  Numbers reserved but not used before the Env is closed are
+// skipped, leaving a gap in the sequence.  The default batch size is 1.
+func (env *Env) SetSequenceBatch(n int) error {
+	if n <= 0 {
+		return errBatchSize
+	}
+	env.seqs.mut.Lock()
+	env.seqs.batch = uint64(n)
+	env.seqs.m = nil
+	env.seqs.mut.Unlock()
+	return nil
+}
+
+// NextSequence returns the next number in the sequence of dbi, starting at
+// one.  Numbers returned in a transaction, or a subtransaction, which aborts
+// are returned again by subsequent calls to NextSequence.
+//
+// NextSequence may only be called on update transactions.  Each range of
+// numbers is reserved following the number stored in SequenceDB, so Envs in
+// other processes sharing the database do not return the same numbers unless
+// one of them moves the sequence backwards with SetSequence.
+func (txn *Txn) NextSequence(dbi DBI) (uint64, error) {
+	if txn.readonly {
+		return 0, errSeqReadonly
+	}
+	key, err := txn.env.names.key(dbi)
+	if err != nil {
+		return 0, err
+	}
+	r, err := txn.seqRange(key)
+	if err != nil {
+		return 0, err
+	}
+	if r.last == r.end {
+		// Another Env may have reserved numbers since r was cached.
+		stored, err := txn.getSequence(key)
+		if err != nil {
+			return 0, err
+		}
+		if stored > r.last {
+			r.last = stored
+		}
+		r.end = r.last + txn.env.seqBatch()
+		err = txn.putSequence(key, r.end)
+		if err != nil {
+			return 0, err
+		}
+	}
+	r.last++
+	txn.setSeqRange(key, r)
+	return r.last, nil
+}
+
+// Sequence returns the last number returned by NextSequence for dbi, or zero
+// if NextSequence has never been called.  In a transaction which has not
+// called NextSequence or SetSequence for dbi, Sequence returns the largest
+// number stored in SequenceDB, which may exceed the last number returned by
+// NextSequence if sequence numbers are reserved in batches.
+func (txn *Txn) Sequence(dbi DBI) (uint64, error) {
+	key, err := txn.env.names.key(dbi)
+	if err != nil {
+		return 0, err
+	}
+	if r, ok := txn.txnSeqRange(key); ok {
+		return r.last, nil
+	}
+	return txn.getSequence(key)
+}
+
+// SetSequence sets the sequence of dbi so that the next call to NextSequence
+// returns v+1.  SetSequence discards any numbers reserved for dbi.
+func (txn *Txn) SetSequence(dbi DBI, v uint64) error {
+	if txn.readonly {
+		return errSeqReadonly
+	}
+	key, err := txn.env.names.key(dbi)
+	if err != nil {
+		return err
+	}
+	err = txn.putSequence(key, v)
+	if err != nil {
+		return err
+	}
+	txn.setSeqRange(key, seqRange{last: v, end: v})
+	return nil
+}
+
+func (env *Env) seqBatch() uint64 {
+	env.seqs.mut.Lock()
+	defer env.seqs.mut.Unlock()
+	if env.seqs.batch == 0 {
+		return 1
+	}
+	return env.seqs.batch
+}
+
+// txnSeqRange returns the range for key modified by txn or its ancestors.
+func (txn *Txn) txnSeqRange(key []byte) (seqRange, bool) {
+	for t := txn; t != nil; t = t.parent {
+		if r, ok := t.seqs[string(key)]; ok {
+			return r, true
+		}
+	}
+	return seqRange{}, false
+}
+
+// seqRange returns the unused numbers reserved for key as seen by txn.
+// Ranges modified by txn and its ancestors take precedence over those of
+// committed transactions.
+func (txn *Txn) seqRange(key []byte) (seqRange, error) {
+	if r, ok := txn.txnSeqRange(key); ok {
+		return r, nil
+	}
+	txn.env.seqs.mut.Lock()
+	r, ok := txn.env.seqs.m[string(key)]
+	txn.env.seqs.mut.Unlock()
+	if ok {
+		return r, nil
+	}
+	v, err := txn.getSequence(key)
+	return seqRange{last: v, end: v}, err
+}
+
+func (txn *Txn) setSeqRange(key []byte, r seqRange) {
+	if txn.seqs == nil {
+		txn.seqs = make(map[string]seqRange)
+	}
+	txn.seqs[string(key)] = r
+}
+
+// getSequence returns the number stored for key in SequenceDB.
+func (txn *Txn) getSequence(key []byte) (uint64, error) {
+	dbi, err := txn.OpenDBI(SequenceDB, 0)
+	if IsNotFound(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	v, err := txn.Get(dbi, key)
+	if IsNotFound(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(v) != 8 {
+		return 0, errSeqCorrupted
+	}
+	return binary.BigEndian.Uint64(v), nil
+}
+
+// putSequence stores v for key in SequenceDB.
+func (txn *Txn) putSequence(key []byte, v uint64) error {
+	dbi, err := txn.OpenDBI(SequenceDB, Create)
+	if err != nil {
+		return err
+	}
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	return txn.Put(dbi, key, b[:], 0)
+}
+
+// finishSeqs transfers the sequence ranges of a committed subtransaction to
+// its parent.  The ranges of a committed top-level transaction are cached by
+// the Env.
+func (txn *Txn) finishSeqs(committed bool, parent *Txn) {
+	seqs := txn.seqs
+	txn.seqs = nil
+	if parent != nil {
+		if committed {
+			for k, r := range seqs {
+				parent.setSeqRange([]byte(k), r)
+			}
+		}
+		return
+	}
+	if !committed || len(seqs) == 0 {
+		return
+	}
+	txn.env.seqs.mut.Lock()
+	if txn.env.seqs.m == nil {
+		txn.env.seqs.m = make(map[string]seqRange)
+	}
+	for k, r := range seqs {
+		txn.env.seqs.m[k] = r
+	}
+	txn.env.seqs.mut.Unlock()
+}
@@ -0,0 +1,346 @@
+package lmdb
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func nextSequence(env *Env, dbi DBI) (seq uint64, err error) {
+	err = env.Update(func(txn *Txn) (err error) {
+		seq, err = txn.NextSequence(dbi)
+		return err
+	})
+	return seq, err
+}
+
+func TestTxn_NextSequence(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	db1, err := openDBI(env, "db1", Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db2, err := openDBI(env, "db2", Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var root DBI
+	err = env.Update(func(txn *Txn) (err error) {
+		root, err = txn.OpenRoot(0)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		dbi DBI
+		seq uint64
+	}{
+		{db1, 1},
+		{db1, 2},
+		{db2, 1},
+		{root, 1},
+		{db1, 3},
+		{root, 2},
+	} {
+		seq, err := nextSequence(env, test.dbi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seq != test.seq {
+			t.Errorf("dbi %d: unexpected sequence: %d (!= %d)", test.dbi, seq, test.seq)
+		}
+	}
+
+	err = env.View(func(txn *Txn) (err error) {
+		seq, err := txn.Sequence(db1)
+		if err != nil {
+			return err
+		}
+		if seq != 3 {
+			t.Errorf("unexpected sequence: %d (!= 3)", seq)
+		}
+		_, err = txn.NextSequence(db1)
+		if err != errSeqReadonly {
+			t.Errorf("unexpected error: %v (!= %v)", err, errSeqReadonly)
+		}
+		_, err = txn.Sequence(DBI(1000))
+		if err != errUnknownDBI {
+			t.Errorf("unexpected error: %v (!= %v)", err, errUnknownDBI)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.Update(func(txn *Txn) (err error) {
+		return txn.SetSequence(db2, 100)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, err := nextSequence(env, db2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq != 101 {
+		t.Errorf("unexpected sequence: %d (!= 101)", seq)
+	}
+}
+
+func TestTxn_NextSequence_abort(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openDBI(env, "db", Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errAbort := errors.New("abort")
+	err = env.Update(func(txn *Txn) (err error) {
+		_, err = txn.NextSequence(dbi)
+		if err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if err != errAbort {
+		t.Fatalf("unexpected error: %v (!= %v)", err, errAbort)
+	}
+
+	err = env.Update(func(txn *Txn) (err error) {
+		seq, err := txn.NextSequence(dbi)
+		if err != nil {
+			return err
+		}
+		if seq != 1 {
+			t.Errorf("unexpected sequence: %d (!= 1)", seq)
+		}
+		err = txn.Sub(func(txn *Txn) (err error) {
+			seq, err := txn.NextSequence(dbi)
+			if err != nil {
+				return err
+			}
+			if seq != 2 {
+				t.Errorf("unexpected sequence: %d (!= 2)", seq)
+			}
+			return errAbort
+		})
+		if err != errAbort {
+			t.Errorf("unexpected error: %v (!= %v)", err, errAbort)
+		}
+		err = txn.Sub(func(txn *Txn) (err error) {
+			_, err = txn.NextSequence(dbi)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		seq, err = txn.Sequence(dbi)
+		if err != nil {
+			return err
+		}
+		if seq != 2 {
+			t.Errorf("unexpected sequence: %d (!= 2)", seq)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnv_SetSequenceBatch(t *testing.T) {
+	env := setup(t)
+	path, err := env.Path()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	defer func() { env.Close() }()
+
+	err = env.SetSequenceBatch(0)
+	if err != errBatchSize {
+		t.Errorf("unexpected error: %v (!= %v)", err, errBatchSize)
+	}
+	err = env.SetSequenceBatch(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbi, err := openDBI(env, "db", Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := uint64(1); i <= 3; i++ {
+		seq, err := nextSequence(env, dbi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seq != i {
+			t.Errorf("unexpected sequence: %d (!= %d)", seq, i)
+		}
+	}
+
+	// Numbers taken from the cache by an aborted transaction are reused.
+	errAbort := errors.New("abort")
+	err = env.Update(func(txn *Txn) (err error) {
+		_, err = txn.NextSequence(dbi)
+		if err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if err != errAbort {
+		t.Fatalf("unexpected error: %v (!= %v)", err, errAbort)
+	}
+	seq, err := nextSequence(env, dbi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq != 4 {
+		t.Errorf("unexpected sequence: %d (!= 4)", seq)
+	}
+
+	err = env.View(func(txn *Txn) (err error) {
+		seq, err := txn.Sequence(dbi)
+		if err != nil {
+			return err
+		}
+		if seq != 10 {
+			t.Errorf("unexpected stored sequence: %d (!= 10)", seq)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reserved numbers are skipped after the environment is reopened.
+	env.Close()
+	env, err = NewEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.SetMaxDBs(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.Open(path, 0, 0664)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbi, err = openDBI(env, "db", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seq, err = nextSequence(env, dbi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq != 11 {
+		t.Errorf("unexpected sequence: %d (!= 11)", seq)
+	}
+}
+
+func TestTxn_NextSequence_sharedEnv(t *testing.T) {
+	env1 := setup(t)
+	defer clean(env1, t)
+	path, err := env1.Path()
+	if err != nil {
+		t.Fatal(err)
+	}
+	env2, err := NewEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env2.Close()
+	err = env2.SetMaxDBs(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env2.Open(path, 0, 0664)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbi1, err := openDBI(env1, "db", Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbi2, err := openDBI(env2, "db", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each Env must reserve numbers following those reserved by the other.
+	for i, test := range []struct {
+		env *Env
+		dbi DBI
+	}{
+		{env1, dbi1},
+		{env2, dbi2},
+		{env1, dbi1},
+		{env2, dbi2},
+	} {
+		seq, err := nextSequence(test.env, test.dbi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seq != uint64(i+1) {
+			t.Errorf("unexpected sequence: %d (!= %d)", seq, i+1)
+		}
+	}
+}
+
+func TestEnv_SetSequenceBatch_txn(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openDBI(env, "db", Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Changing the batch size does not block on a transaction using
+	// sequences.
+	err = env.Update(func(txn *Txn) (err error) {
+		_, err = txn.NextSequence(dbi)
+		if err != nil {
+			return err
+		}
+		err = env.SetSequenceBatch(5)
+		if err != nil {
+			return err
+		}
+		seq, err := txn.NextSequence(dbi)
+		if err != nil {
+			return err
+		}
+		if seq != 2 {
+			t.Errorf("unexpected sequence: %d (!= 2)", seq)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.View(func(txn *Txn) (err error) {
+		seq, err := txn.Sequence(dbi)
+		if err != nil {
+			return err
+		}
+		if seq != 6 {
+			t.Errorf("unexpected stored sequence: %d (!= 6)", seq)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -18,15 +18,19 @@ import (
 // Create flag must always be supplied when opening a non-root DBI for the
 // first time.
 //
-// BUG(bmatsuo):
-// MDB_INTEGERKEY and MDB_INTEGERDUP aren't usable. I'm not sure they would be
-// faster with the cgo bridge.  They need to be tested and benchmarked.
+// Keys in an IntegerKey database (and values in an IntegerDup database) must
+// all be either 4 or 8 bytes in length, stored in the native byte order.  The
+// methods GetUint64, PutUint64, etc. on Txn and Cursor pass native integer
+// keys through the cgo bridge without conversion to []byte.  On platforms
+// with 32-bit pointers only 4-byte integers are supported.
 const (
 	// Flags for Txn.OpenDBI.
 
 	ReverseKey = C.MDB_REVERSEKEY // Use reverse string keys.
 	DupSort    = C.MDB_DUPSORT    // Use sorted duplicates.
+	IntegerKey = C.MDB_INTEGERKEY // Numeric keys in native byte order (uint32 or uint64).
 	DupFixed   = C.MDB_DUPFIXED   // Duplicate items have a fixed size (DupSort).
+	IntegerDup = C.MDB_INTEGERDUP // Duplicate items are numbers in native byte order (DupSort and DupFixed).
 	ReverseDup = C.MDB_REVERSEDUP // Reverse duplicate values (DupSort).
 	Create     = C.MDB_CREATE     // Create DB if not already existing.
 )
@@ -63,6 +67,28 @@ type Txn struct {
 	key  *C.MDB_val
 	val  *C.MDB_val
 
+	// ctx holds the context.Context passed to Env.ViewContext,
+	// Env.UpdateContext, or Env.RunTxnContext.  Its type is interface{} so
+	// that the package may be built with versions of Go prior to 1.7.
+	ctx interface{}
+
+	// parent is the parent of a subtransaction, which receives its hooks
+	// when it commits.
+	parent   *Txn
+	onCommit []func()
+	onAbort  []func()
+
+	// changes is non-nil for update transactions begun while the Env has
+	// observers.
+	changes *changeLog
+
+	// trackID identifies a read transaction tracked by the Watchdog of its
+	// Env.
+	trackID uint64
+
+	// seqs holds the sequence ranges modified by txn.
+	seqs map[string]seqRange
+
 	errLogf func(format string, v ...interface{})
 }
 
@@ -95,11 +121,20 @@ func beginTxn(env *Env, parent *Txn, flags uint) (*Txn, error) {
 		ptxn = parent._txn
 		txn.key = parent.key
 		txn.val = parent.val
+		txn.ctx = parent.ctx
+		txn.parent = parent
 	}
 	ret := C.mdb_txn_begin(env._env, ptxn, C.uint(flags), &txn._txn)
 	if ret != success {
 		return nil, operrno("mdb_txn_begin", ret)
 	}
+	if flags&Readonly == 0 {
+		if parent == nil && env.observing() || parent != nil && parent.changes != nil {
+			txn.changes = new(changeLog)
+		}
+	} else {
+		txn.track()
+	}
 	return txn, nil
 }
 
@@ -190,8 +225,16 @@ func (txn *Txn) Commit() error {
 }
 
 func (txn *Txn) commit() error {
+	var id uintptr
+	if txn.changes != nil {
+		id = txn.ID()
+	}
 	ret := C.mdb_txn_commit(txn._txn)
 	txn.clearTxn()
+	txn.untrack()
+	txn.finishChanges(ret == success, id, txn.parent)
+	txn.finishSeqs(ret == success, txn.parent)
+	txn.runHooks(ret == success)
 	return operrno("mdb_txn_commit", ret)
 }
 
@@ -222,6 +265,50 @@ func (txn *Txn) abort() {
 	txn.env.closeLock.RUnlock()
 
 	txn.clearTxn()
+	txn.untrack()
+	txn.finishChanges(false, 0, nil)
+	txn.finishSeqs(false, txn.parent)
+	txn.runHooks(false)
+}
+
+// OnCommit registers fn to be called after txn has been committed
+// successfully.  If txn is a subtransaction fn is called only after its
+// top-level transaction commits, and it is not called if txn or any of its
+// ancestors are aborted.  Functions are called in the order they were
+// registered, from the goroutine which commits the transaction.
+func (txn *Txn) OnCommit(fn func()) {
+	txn.onCommit = append(txn.onCommit, fn)
+}
+
+// OnAbort registers fn to be called after txn is aborted, or after an attempt
+// to commit txn fails.  If txn is a subtransaction that commits successfully
+// fn is called if any of its ancestors are subsequently aborted.  Functions
+// are called in the order they were registered.
+//
+// A Txn which is not terminated explicitly may be aborted by its finalizer, in
+// which case fn is called from the finalizer goroutine.
+func (txn *Txn) OnAbort(fn func()) {
+	txn.onAbort = append(txn.onAbort, fn)
+}
+
+// runHooks calls the functions registered with OnCommit if txn was committed,
+// or those registered with OnAbort otherwise.  When a subtransaction commits
+// its hooks are instead transferred to its parent.
+func (txn *Txn) runHooks(committed bool) {
+	onCommit, onAbort, parent := txn.onCommit, txn.onAbort, txn.parent
+	txn.onCommit, txn.onAbort, txn.parent = nil, nil, nil
+	if committed && parent != nil {
+		parent.onCommit = append(parent.onCommit, onCommit...)
+		parent.onAbort = append(parent.onAbort, onAbort...)
+		return
+	}
+	hooks := onAbort
+	if committed {
+		hooks = onCommit
+	}
+	for _, fn := range hooks {
+		fn()
+	}
 }
 
 func (txn *Txn) clearTxn() {
@@ -260,6 +347,7 @@ func (txn *Txn) Reset() {
 
 func (txn *Txn) reset() {
 	C.mdb_txn_reset(txn._txn)
+	txn.untrack()
 }
 
 // Renew reuses a transaction that was previously reset by calling txn.Reset().
@@ -285,6 +373,9 @@ func (txn *Txn) renew() error {
 	// results in the freeing of stale pages the Txn has been holding, though
 	// this has not been confirmed in any way by bmatsuo as of 2017-02-15.
 	txn.resetID()
+	if ret == success {
+		txn.track()
+	}
 
 	return operrno("mdb_txn_renew", ret)
 }
@@ -335,6 +426,13 @@ func (txn *Txn) OpenRoot(flags uint) (DBI, error) {
 func (txn *Txn) openDBI(cname *C.char, flags uint) (DBI, error) {
 	var dbi C.MDB_dbi
 	ret := C.mdb_dbi_open(txn._txn, cname, C.uint(flags), &dbi)
+	if ret == success {
+		var name string
+		if cname != nil {
+			name = C.GoString(cname)
+		}
+		txn.env.names.set(DBI(dbi), name)
+	}
 	return DBI(dbi), operrno("mdb_dbi_open", ret)
 }
 
@@ -362,6 +460,12 @@ func (txn *Txn) Stat(dbi DBI) (*Stat, error) {
 // See mdb_drop.
 func (txn *Txn) Drop(dbi DBI, del bool) error {
 	ret := C.mdb_drop(txn._txn, C.MDB_dbi(dbi), cbool(del))
+	if ret == success && txn.changes != nil {
+		txn.record(dbi, ChangeDrop, nil)
+	}
+	if ret == success && del {
+		txn.env.names.del(dbi)
+	}
 	return operrno("mdb_drop", ret)
 }
 
@@ -425,6 +529,98 @@ func (txn *Txn) Get(dbi DBI, key []byte) ([]byte, error) {
 	return b, nil
 }
 
+// GetMany retrieves the values for keys from database dbi and stores them in
+// r, crossing the cgo boundary once regardless of the number of keys.  Keys
+// which are not found do not cause GetMany to fail and are reported by
+// r.Err.  If any other error is encountered it is returned and the contents
+// of r are unspecified.
+//
+// If txn.RawRead is true the values stored in r reference readonly sections
+// of memory that must not be accessed after txn has terminated.
+//
+// See mdb_get.
+func (txn *Txn) GetMany(dbi DBI, keys [][]byte, r *GetManyResult) error {
+	if len(keys) == 0 {
+		r.reset()
+		return nil
+	}
+	kdata, koff, vals, rets := r.pack(keys)
+	ret := C.lmdbgo_mdb_get_many(
+		txn._txn, C.MDB_dbi(dbi),
+		kdata, koff, C.size_t(len(keys)),
+		vals, rets,
+	)
+	r.unpack(txn)
+	return operrno("mdb_get", ret)
+}
+
+// GetUint64 retrieves the item with integer key k from database dbi, which
+// is typically opened with the IntegerKey flag.  GetUint64 otherwise behaves
+// like Get.
+//
+// See mdb_get.
+func (txn *Txn) GetUint64(dbi DBI, k uint64) ([]byte, error) {
+	ret := C.lmdbgo_mdb_get_uint64(txn._txn, C.MDB_dbi(dbi), C.uint64_t(k), txn.val)
+	return txn.getIntVal(ret)
+}
+
+// GetUint32 retrieves the item with integer key k from database dbi, which
+// is typically opened with the IntegerKey flag.  GetUint32 otherwise behaves
+// like Get.
+//
+// See mdb_get.
+func (txn *Txn) GetUint32(dbi DBI, k uint32) ([]byte, error) {
+	ret := C.lmdbgo_mdb_get_uint32(txn._txn, C.MDB_dbi(dbi), C.uint32_t(k), txn.val)
+	return txn.getIntVal(ret)
+}
+
+func (txn *Txn) getIntVal(ret C.int) ([]byte, error) {
+	err := operrno("mdb_get", ret)
+	if err != nil {
+		*txn.val = C.MDB_val{}
+		return nil, err
+	}
+	b := txn.bytes(txn.val)
+	*txn.val = C.MDB_val{}
+	return b, nil
+}
+
+// PutUint64 stores an item with integer key k in database dbi, which is
+// typically opened with the IntegerKey flag.
+//
+// See mdb_put.
+func (txn *Txn) PutUint64(dbi DBI, k uint64, val []byte, flags uint) error {
+	vdata, vn := valBytes(val)
+	ret := C.lmdbgo_mdb_put_uint64(
+		txn._txn, C.MDB_dbi(dbi),
+		C.uint64_t(k),
+		(*C.char)(unsafe.Pointer(&vdata[0])), C.size_t(vn),
+		C.uint(flags),
+	)
+	if ret == success && txn.changes != nil {
+		txn.recordUint64(dbi, k)
+	}
+	return operrno("mdb_put", ret)
+}
+
+// PutUint32 stores an item with integer key k in database dbi, which is
+// typically opened with the IntegerKey flag.
+//
+// See mdb_put.
+func (txn *Txn) PutUint32(dbi DBI, k uint32, val []byte, flags uint) error {
+	vdata, vn := valBytes(val)
+	ret := C.lmdbgo_mdb_put_uint32(
+		txn._txn, C.MDB_dbi(dbi),
+		C.uint32_t(k),
+		(*C.char)(unsafe.Pointer(&vdata[0])), C.size_t(vn),
+		C.uint(flags),
+	)
+	if ret == success && txn.changes != nil {
+		txn.recordUint32(dbi, k)
+	}
+	return operrno("mdb_put", ret)
+}
+
 func (txn *Txn) putNilKey(dbi DBI, flags uint) error {
 	// mdb_put with an empty key will always fail
 	ret := C.lmdbgo_mdb_put2(txn._txn, C.MDB_dbi(dbi), nil, 0, nil, 0, C.uint(flags))
@@ -450,9 +646,40 @@ func (txn *Txn) Put(dbi DBI, key []byte, val []byte, flags uint) error {
 		(*C.char)(unsafe.Pointer(&val[0])), C.size_t(vn),
 		C.uint(flags),
 	)
+	if ret == success && txn.changes != nil {
+		txn.record(dbi, ChangePut, key)
+	}
 	return operrno("mdb_put", ret)
 }
 
+// PutBatch stores the key-value pairs in b using flags, crossing the cgo
+// boundary once regardless of the number of pairs.  Typical flags are
+// NoOverwrite, NoDupData, Append, and AppendDup.
+//
+// PutBatch returns the number of pairs stored.  If an error is encountered
+// while storing pair n it is returned and no later pairs are attempted.  The
+// caller may handle the error and resume by calling PutBatch with b.Slice(n)
+// or b.Slice(n+1).
+//
+// See mdb_put.
+func (txn *Txn) PutBatch(dbi DBI, b *PutBuffer, flags uint) (n int, err error) {
+	if b.Len() == 0 {
+		return 0, nil
+	}
+	data, off := b.pack()
+	var count C.size_t
+	ret := C.lmdbgo_mdb_put_batch(
+		txn._txn, C.MDB_dbi(dbi),
+		data, off, C.size_t(b.Len()),
+		C.uint(flags),
+		&count,
+	)
+	if txn.changes != nil {
+		txn.recordBatch(dbi, b, int(count))
+	}
+	return int(count), operrno("mdb_put", ret)
+}
+
 // PutReserve returns a []byte of length n that can be written to, potentially
 // avoiding a memcopy.  The returned byte slice is only valid in txn's thread,
 // before it has terminated.
@@ -472,6 +699,9 @@ func (txn *Txn) PutReserve(dbi DBI, key []byte, n int, flags uint) ([]byte, erro
 		*txn.val = C.MDB_val{}
 		return nil, err
 	}
+	if txn.changes != nil {
+		txn.record(dbi, ChangePut, key)
+	}
 	b := getBytes(txn.val)
 	*txn.val = C.MDB_val{}
 	return b, nil
@@ -489,6 +719,9 @@ func (txn *Txn) Del(dbi DBI, key, val []byte) error {
 		(*C.char)(unsafe.Pointer(&kdata[0])), C.size_t(kn),
 		(*C.char)(unsafe.Pointer(&vdata[0])), C.size_t(vn),
 	)
+	if ret == success && txn.changes != nil {
+		txn.record(dbi, ChangeDel, key)
+	}
 	return operrno("mdb_del", ret)
 }
 
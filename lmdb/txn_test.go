@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"os"
+	"reflect"
 	"runtime"
 	"syscall"
 	"testing"
@@ -298,6 +299,65 @@ func TestTexn_Put_emptyValue(t *testing.T) {
 	}
 }
 
+func TestTxn_PutBatch(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	err := env.Update(func(txn *Txn) (err error) {
+		dbi, err := txn.OpenDBI("testdb", Create)
+		if err != nil {
+			return err
+		}
+		err = txn.Put(dbi, []byte("b"), []byte("x"), 0)
+		if err != nil {
+			return err
+		}
+
+		b := new(PutBuffer)
+		n, err := txn.PutBatch(dbi, b, 0)
+		if n != 0 || err != nil {
+			t.Errorf("unexpected result for empty batch: %d %v", n, err)
+		}
+
+		b.Add([]byte("a"), []byte("1"))
+		b.Add([]byte("b"), []byte("2"))
+		b.Add([]byte("c"), nil)
+		b.Add([]byte("d"), []byte("4"))
+		if b.Len() != 4 {
+			t.Errorf("unexpected buffer length: %d (!= 4)", b.Len())
+		}
+		n, err = txn.PutBatch(dbi, b, NoOverwrite)
+		if !IsErrno(err, KeyExist) {
+			t.Errorf("unexpected error: %v (!= %v)", err, KeyExist)
+		}
+		if n != 1 {
+			t.Errorf("unexpected number of items stored: %d (!= 1)", n)
+		}
+		rest := b.Slice(n + 1)
+		n, err = txn.PutBatch(dbi, rest, NoOverwrite)
+		if err != nil {
+			return err
+		}
+		if n != rest.Len() {
+			t.Errorf("unexpected number of items stored: %d (!= %d)", n, rest.Len())
+		}
+
+		for _, item := range [][2]string{{"a", "1"}, {"b", "x"}, {"c", ""}, {"d", "4"}} {
+			v, err := txn.Get(dbi, []byte(item[0]))
+			if err != nil {
+				return err
+			}
+			if string(v) != item[1] {
+				t.Errorf("unexpected value for %q: %q (!= %q)", item[0], v, item[1])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
 func TestTxn_PutReserve(t *testing.T) {
 	env := setup(t)
 	defer clean(env, t)
@@ -337,6 +397,224 @@ func TestTxn_PutReserve(t *testing.T) {
 	}
 }
 
+func TestTxn_GetMany(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	var dbi DBI
+	err := env.Update(func(txn *Txn) (err error) {
+		dbi, err = txn.OpenDBI("testdb", Create)
+		if err != nil {
+			return err
+		}
+		for _, k := range []string{"a", "c", "e"} {
+			err = txn.Put(dbi, []byte(k), []byte(k+k), 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := [][]byte{[]byte("e"), []byte("b"), []byte("a"), []byte("f"), []byte("c")}
+	exp := []string{"ee", "", "aa", "", "cc"}
+	res := new(GetManyResult)
+	for _, raw := range []bool{false, true} {
+		err = env.View(func(txn *Txn) (err error) {
+			txn.RawRead = raw
+			err = txn.GetMany(dbi, keys, res)
+			if err != nil {
+				return err
+			}
+			if res.Len() != len(keys) {
+				t.Errorf("unexpected result length: %d (!= %d)", res.Len(), len(keys))
+			}
+			for i := range keys {
+				v, err := res.Val(i), res.Err(i)
+				if exp[i] == "" {
+					if !IsNotFound(err) {
+						t.Errorf("key %q: expected NotFound: %v", keys[i], err)
+					}
+					if v != nil {
+						t.Errorf("key %q: unexpected value: %q", keys[i], v)
+					}
+				} else if err != nil {
+					t.Errorf("key %q: %v", keys[i], err)
+				} else if string(v) != exp[i] {
+					t.Errorf("key %q: unexpected value: %q (!= %q)", keys[i], v, exp[i])
+				}
+			}
+
+			err = txn.GetMany(dbi, nil, res)
+			if err != nil {
+				return err
+			}
+			if res.Len() != 0 {
+				t.Errorf("unexpected result length: %d (!= 0)", res.Len())
+			}
+			return nil
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestTxn_OnCommit(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	var events []string
+	record := func(s string) func() {
+		return func() { events = append(events, s) }
+	}
+
+	errAbort := fmt.Errorf("abort")
+	err := env.Update(func(txn *Txn) (err error) {
+		txn.OnCommit(record("commit"))
+		txn.OnAbort(record("abort"))
+		err = txn.Sub(func(txn *Txn) error {
+			txn.OnCommit(record("sub commit"))
+			txn.OnAbort(record("sub abort"))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		err = txn.Sub(func(txn *Txn) error {
+			txn.OnCommit(record("failed sub commit"))
+			txn.OnAbort(record("failed sub abort"))
+			return errAbort
+		})
+		if err != errAbort {
+			t.Errorf("unexpected error: %v (!= %v)", err, errAbort)
+		}
+		if len(events) != 1 || events[0] != "failed sub abort" {
+			t.Errorf("unexpected events: %q", events)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []string{"failed sub abort", "commit", "sub commit"}
+	if !reflect.DeepEqual(events, exp) {
+		t.Errorf("unexpected events: %q (!= %q)", events, exp)
+	}
+
+	events = nil
+	err = env.Update(func(txn *Txn) (err error) {
+		txn.OnCommit(record("commit"))
+		txn.OnAbort(record("abort"))
+		err = txn.Sub(func(txn *Txn) error {
+			txn.OnCommit(record("sub commit"))
+			txn.OnAbort(record("sub abort"))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if err != errAbort {
+		t.Errorf("unexpected error: %v (!= %v)", err, errAbort)
+	}
+	exp = []string{"abort", "sub abort"}
+	if !reflect.DeepEqual(events, exp) {
+		t.Errorf("unexpected events: %q (!= %q)", events, exp)
+	}
+
+	// unmanaged transactions run hooks when they terminate.
+	events = nil
+	txn, err := env.BeginTxn(nil, Readonly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txn.OnCommit(record("commit"))
+	txn.OnAbort(record("abort"))
+	txn.Abort()
+	txn.Abort()
+	exp = []string{"abort"}
+	if !reflect.DeepEqual(events, exp) {
+		t.Errorf("unexpected events: %q (!= %q)", events, exp)
+	}
+}
+
+func TestTxn_PutUint64(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	keys := []uint64{1 << 40, 3, 1 << 8, 0, 2}
+	err := env.Update(func(txn *Txn) (err error) {
+		dbi, err := txn.OpenDBI("testdb", Create|IntegerKey)
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			err = txn.PutUint64(dbi, k, []byte(fmt.Sprint(k)), 0)
+			if err != nil {
+				return err
+			}
+		}
+		for _, k := range keys {
+			v, err := txn.GetUint64(dbi, k)
+			if err != nil {
+				return err
+			}
+			if string(v) != fmt.Sprint(k) {
+				t.Errorf("unexpected value for key %d: %q", k, v)
+			}
+		}
+		_, err = txn.GetUint64(dbi, 4)
+		if !IsNotFound(err) {
+			t.Errorf("expected NotFound: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTxn_PutUint32(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	keys := []uint32{1 << 20, 3, 1 << 8, 0, 2}
+	err := env.Update(func(txn *Txn) (err error) {
+		dbi, err := txn.OpenDBI("testdb", Create|IntegerKey)
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			err = txn.PutUint32(dbi, k, []byte(fmt.Sprint(k)), 0)
+			if err != nil {
+				return err
+			}
+		}
+		for _, k := range keys {
+			v, err := txn.GetUint32(dbi, k)
+			if err != nil {
+				return err
+			}
+			if string(v) != fmt.Sprint(k) {
+				t.Errorf("unexpected value for key %d: %q", k, v)
+			}
+		}
+		_, err = txn.GetUint32(dbi, 4)
+		if !IsNotFound(err) {
+			t.Errorf("expected NotFound: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
 func TestTxn_bytesBuffer(t *testing.T) {
 	env := setup(t)
 	defer clean(env, t)
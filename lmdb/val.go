@@ -54,6 +54,26 @@ func WrapMulti(page []byte, stride int) *Multi {
 	return &Multi{page: page, stride: stride}
 }
 
+// WrapMultiUint64 returns a Multi containing a copy of vals in the native
+// byte order, suitable for Cursor.PutMulti in an IntegerDup database.
+func WrapMultiUint64(vals []uint64) *Multi {
+	page := make([]byte, 8*len(vals))
+	if len(vals) > 0 {
+		copy(page, (*[valMaxSize]byte)(unsafe.Pointer(&vals[0]))[:len(page)])
+	}
+	return &Multi{page: page, stride: 8}
+}
+
+// WrapMultiUint32 returns a Multi containing a copy of vals in the native
+// byte order, suitable for Cursor.PutMulti in an IntegerDup database.
+func WrapMultiUint32(vals []uint32) *Multi {
+	page := make([]byte, 4*len(vals))
+	if len(vals) > 0 {
+		copy(page, (*[valMaxSize]byte)(unsafe.Pointer(&vals[0]))[:len(page)])
+	}
+	return &Multi{page: page, stride: 4}
+}
+
 // Vals returns a slice containing the values in m.  The returned slice has
 // length m.Len() and each item has length m.Stride().
 func (m *Multi) Vals() [][]byte {
@@ -71,6 +91,29 @@ func (m *Multi) Val(i int) []byte {
 	return m.page[off : off+m.stride]
 }
 
+// Uint64 returns the value at index i as an integer in the native byte order.
+// Uint64 panics if i is out of range or m.Stride() is not 8.
+func (m *Multi) Uint64(i int) uint64 {
+	var x uint64
+	copy((*[8]byte)(unsafe.Pointer(&x))[:], m.intVal(i, 8))
+	return x
+}
+
+// Uint32 returns the value at index i as an integer in the native byte order.
+// Uint32 panics if i is out of range or m.Stride() is not 4.
+func (m *Multi) Uint32(i int) uint32 {
+	var x uint32
+	copy((*[4]byte)(unsafe.Pointer(&x))[:], m.intVal(i, 4))
+	return x
+}
+
+func (m *Multi) intVal(i int, size int) []byte {
+	if m.stride != size {
+		panic("incongruent stride")
+	}
+	return m.Val(i)
+}
+
 // Len returns the number of values in the Multi.
 func (m *Multi) Len() int {
 	return len(m.page) / m.stride
@@ -95,6 +138,205 @@ func (m *Multi) Page() []byte {
 	return m.page[:len(m.page):len(m.page)]
 }
 
+// GetManyResult holds the values retrieved by a call to Txn.GetMany or
+// Cursor.GetMany.  A GetManyResult may be reused to amortize allocation.
+//
+// If the transaction used to retrieve values has RawRead set to true the
+// values in a GetManyResult reference readonly sections of memory that must
+// not be accessed after the transaction has terminated.
+type GetManyResult struct {
+	vals  [][]byte
+	rets  []C.int
+	cvals []C.MDB_val
+	kbuf  []byte
+	koff  []C.size_t
+}
+
+// Len returns the number of keys looked up by the last call which used r.
+func (r *GetManyResult) Len() int {
+	return len(r.vals)
+}
+
+// Val returns the value found for key i, or nil if the key was not found.
+func (r *GetManyResult) Val(i int) []byte {
+	return r.vals[i]
+}
+
+// Err returns an error for which IsNotFound returns true if key i was not
+// found.  Otherwise Err returns nil.
+func (r *GetManyResult) Err(i int) error {
+	return operrno("mdb_get", r.rets[i])
+}
+
+// reset clears r for a call with no keys.
+func (r *GetManyResult) reset() {
+	r.vals = r.vals[:0]
+	r.rets = r.rets[:0]
+	r.cvals = r.cvals[:0]
+}
+
+// pack copies keys into r so they may be passed to C.  pack returns pointers
+// to the C arguments describing the keys and result storage.  The keys slice
+// must not be empty.
+func (r *GetManyResult) pack(keys [][]byte) (kdata *C.char, koff *C.size_t, vals *C.MDB_val, rets *C.int) {
+	n := len(keys)
+	r.kbuf = r.kbuf[:0]
+	r.koff = append(r.koff[:0], 0)
+	for _, k := range keys {
+		r.kbuf = append(r.kbuf, k...)
+		r.koff = append(r.koff, C.size_t(len(r.kbuf)))
+	}
+	if cap(r.cvals) < n {
+		r.cvals = make([]C.MDB_val, n)
+		r.rets = make([]C.int, n)
+		r.vals = make([][]byte, n)
+	}
+	r.cvals = r.cvals[:n]
+	r.rets = r.rets[:n]
+	r.vals = r.vals[:n]
+	for i := range r.rets {
+		r.rets[i] = C.MDB_NOTFOUND
+	}
+	kbuf, _ := valBytes(r.kbuf)
+	return (*C.char)(unsafe.Pointer(&kbuf[0])), &r.koff[0], &r.cvals[0], &r.rets[0]
+}
+
+// unpack converts the C values stored in r using txn and clears them to
+// prevent dangling references.
+func (r *GetManyResult) unpack(txn *Txn) {
+	for i := range r.cvals {
+		if r.rets[i] == C.MDB_SUCCESS {
+			r.vals[i] = txn.bytes(&r.cvals[i])
+		} else {
+			r.vals[i] = nil
+		}
+		r.cvals[i] = C.MDB_val{}
+	}
+}
+
+// RecordBatch holds key-value pairs retrieved by a call to Cursor.GetBatch.
+// A RecordBatch is reused by each call to GetBatch to amortize allocation.
+//
+// If the transaction used to retrieve records has RawRead set to true the
+// keys and values in a RecordBatch reference readonly sections of memory that
+// must not be accessed after the transaction has terminated.
+type RecordBatch struct {
+	keys  [][]byte
+	vals  [][]byte
+	ckeys []C.MDB_val
+	cvals []C.MDB_val
+}
+
+// NewRecordBatch allocates a RecordBatch which can hold up to size records.
+// NewRecordBatch panics if size is not positive.
+func NewRecordBatch(size int) *RecordBatch {
+	if size <= 0 {
+		panic("batch size must be positive")
+	}
+	return &RecordBatch{
+		keys:  make([][]byte, 0, size),
+		vals:  make([][]byte, 0, size),
+		ckeys: make([]C.MDB_val, size),
+		cvals: make([]C.MDB_val, size),
+	}
+}
+
+// Cap returns the maximum number of records b can hold.
+func (b *RecordBatch) Cap() int {
+	return len(b.ckeys)
+}
+
+// Len returns the number of records retrieved by the last call to GetBatch.
+func (b *RecordBatch) Len() int {
+	return len(b.keys)
+}
+
+// Key returns the key of record i.
+func (b *RecordBatch) Key(i int) []byte {
+	return b.keys[i]
+}
+
+// Val returns the value of record i.
+func (b *RecordBatch) Val(i int) []byte {
+	return b.vals[i]
+}
+
+// unpack converts the first n C records stored in b using txn and clears
+// them to prevent dangling references.
+func (b *RecordBatch) unpack(txn *Txn, n int) {
+	b.keys = b.keys[:n]
+	b.vals = b.vals[:n]
+	for i := 0; i < n; i++ {
+		b.keys[i] = txn.bytes(&b.ckeys[i])
+		b.vals[i] = txn.bytes(&b.cvals[i])
+		b.ckeys[i] = C.MDB_val{}
+		b.cvals[i] = C.MDB_val{}
+	}
+}
+
+// PutBuffer is a packed sequence of key-value pairs which can be stored in a
+// database using a single cgo call with Txn.PutBatch or Cursor.PutBatch.  The
+// zero value is an empty PutBuffer ready to use.  Keys and values are copied
+// into the buffer so the slices passed to Add may be reused immediately.
+type PutBuffer struct {
+	data []byte
+	off  []C.size_t
+}
+
+// Add appends a key-value pair to b.
+func (b *PutBuffer) Add(key, val []byte) {
+	if len(b.off) == 0 {
+		b.off = append(b.off, 0)
+	}
+	b.data = append(b.data, key...)
+	b.off = append(b.off, C.size_t(len(b.data)))
+	b.data = append(b.data, val...)
+	b.off = append(b.off, C.size_t(len(b.data)))
+}
+
+// Len returns the number of key-value pairs in b.
+func (b *PutBuffer) Len() int {
+	if len(b.off) == 0 {
+		return 0
+	}
+	return (len(b.off) - 1) / 2
+}
+
+// Key returns the key of pair i.  The returned slice references memory owned
+// by b and is only valid until b is modified.
+func (b *PutBuffer) Key(i int) []byte {
+	return b.data[b.off[2*i]:b.off[2*i+1]]
+}
+
+// Val returns the value of pair i.  The returned slice references memory
+// owned by b and is only valid until b is modified.
+func (b *PutBuffer) Val(i int) []byte {
+	return b.data[b.off[2*i+1]:b.off[2*i+2]]
+}
+
+// Reset removes all pairs from b, retaining its allocated memory.
+func (b *PutBuffer) Reset() {
+	b.data = b.data[:0]
+	b.off = b.off[:0]
+}
+
+// Slice returns a PutBuffer containing the pairs of b starting at index i,
+// which may be used to resume after PutBatch fails.  The returned PutBuffer
+// shares memory with b and must not be modified.
+func (b *PutBuffer) Slice(i int) *PutBuffer {
+	if i >= b.Len() {
+		return &PutBuffer{}
+	}
+	return &PutBuffer{data: b.data, off: b.off[2*i:]}
+}
+
+// pack returns pointers to the C arguments describing the pairs in b, which
+// must not be empty.
+func (b *PutBuffer) pack() (data *C.char, off *C.size_t) {
+	p, _ := valBytes(b.data)
+	return (*C.char)(unsafe.Pointer(&p[0])), &b.off[0]
+}
+
 var eb = []byte{0}
 
 func valBytes(b []byte) ([]byte, int) {
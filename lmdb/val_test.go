@@ -31,6 +31,32 @@ func TestMultiVal(t *testing.T) {
 	}
 }
 
+func TestMultiVal_integer(t *testing.T) {
+	m64 := WrapMultiUint64([]uint64{1, 1 << 40, 0})
+	if m64.Len() != 3 || m64.Stride() != 8 {
+		t.Errorf("unexpected shape: %d x %d", m64.Len(), m64.Stride())
+	}
+	for i, x := range []uint64{1, 1 << 40, 0} {
+		if m64.Uint64(i) != x {
+			t.Errorf("unexpected value %d: %d (!= %d)", i, m64.Uint64(i), x)
+		}
+	}
+
+	m32 := WrapMultiUint32([]uint32{5, 1 << 20})
+	if m32.Len() != 2 || m32.Stride() != 4 {
+		t.Errorf("unexpected shape: %d x %d", m32.Len(), m32.Stride())
+	}
+	for i, x := range []uint32{5, 1 << 20} {
+		if m32.Uint32(i) != x {
+			t.Errorf("unexpected value %d: %d (!= %d)", i, m32.Uint32(i), x)
+		}
+	}
+
+	if WrapMultiUint64(nil).Len() != 0 {
+		t.Errorf("unexpected values")
+	}
+}
+
 func TestMultiVal_panic(t *testing.T) {
 	var p bool
 	defer func() {
@@ -0,0 +1,270 @@
+package lmdb
+
+/*
+#include "lmdb.h"
+*/
+import "C"
+
+import (
+	"bytes"
+	"fmt"
+	"unsafe"
+)
+
+// numMetas is the number of meta pages at the beginning of a data file.
+const numMetas = 2
+
+// The databases used internally by LMDB.
+const (
+	freeDBI DBI = 0
+	mainDBI DBI = 1
+)
+
+// VerifyError describes an inconsistency found by Verify.
+type VerifyError struct {
+	DB  string // Name of the database, empty for the main database and freelist
+	Key []byte // Key at which the inconsistency was found, if any
+	Msg string
+}
+
+func (e *VerifyError) Error() string {
+	var db string
+	if e.DB != "" {
+		db = fmt.Sprintf("database %q: ", e.DB)
+	}
+	if e.Key != nil {
+		return fmt.Sprintf("%skey %q: %s", db, e.Key, e.Msg)
+	}
+	return db + e.Msg
+}
+
+// VerifyReport is the result of verifying an environment.
+type VerifyReport struct {
+	DBs       []string // Named databases which were verified
+	Entries   uint64   // Items in all databases, including the main database
+	Pages     int64    // Pages used by databases, the freelist, and meta pages
+	FreePages int64    // Pages in the freelist
+
+	// LastPNO is the last page used by the snapshot which was verified.
+	// Page accounting is only checked when the snapshot is the latest in
+	// the environment, otherwise LastPNO is -1.
+	LastPNO int64
+
+	// Exact is true if Pages is expected to be LastPNO+1.  The pages
+	// holding the duplicates of keys in DupSort databases are not counted
+	// by Txn.Stat, so when the environment contains DupSort databases Pages
+	// is only checked not to exceed LastPNO+1.
+	Exact bool
+
+	Errors []*VerifyError
+}
+
+// OK returns true if no inconsistencies were found.
+func (r *VerifyReport) OK() bool {
+	return len(r.Errors) == 0
+}
+
+func (r *VerifyReport) errorf(db string, key []byte, format string, v ...interface{}) {
+	if key != nil {
+		key = append([]byte(nil), key...)
+	}
+	r.Errors = append(r.Errors, &VerifyError{
+		DB:  db,
+		Key: key,
+		Msg: fmt.Sprintf(format, v...),
+	})
+}
+
+// Verify checks the consistency of the snapshot viewed by txn.  Every item in
+// the main database and each named database is visited to check that keys
+// (and the values of DupSort databases) are in order and that the number of
+// items matches Txn.Stat.  The freelist is checked for invalid and repeated
+// pages and, if txn views the latest snapshot, the pages used by all
+// databases and the freelist are checked against the last page used in the
+// environment.
+//
+// Databases are compared using the functions set for their DBI.  Named
+// databases which use a custom comparison function must be opened and have
+// their functions set before Verify is called otherwise they will be reported
+// as being out of order.  Verify opens every named database, so the Env must
+// allow enough databases (see Env.SetMaxDBs).
+//
+// The error returned by Verify is non-nil only if the verification could not
+// be completed.  Inconsistencies are reported in the returned VerifyReport.
+func (txn *Txn) Verify() (*VerifyReport, error) {
+	info, err := txn.env.Info()
+	if err != nil {
+		return nil, err
+	}
+	r := &VerifyReport{LastPNO: -1, Exact: true}
+	latest := txn.readonly && info.LastTxnID == int64(txn.ID())
+
+	var pages int64
+	stat, err := txn.verifyDB(r, "", mainDBI)
+	if err != nil {
+		return nil, err
+	}
+	pages += int64(stat.BranchPages + stat.LeafPages + stat.OverflowPages)
+
+	for _, name := range r.DBs {
+		dbi, err := txn.OpenDBI(name, 0)
+		if err != nil {
+			return nil, err
+		}
+		stat, err := txn.verifyDB(r, name, dbi)
+		if err != nil {
+			return nil, err
+		}
+		pages += int64(stat.BranchPages + stat.LeafPages + stat.OverflowPages)
+	}
+
+	stat, err = txn.Stat(freeDBI)
+	if err != nil {
+		return nil, err
+	}
+	pages += int64(stat.BranchPages + stat.LeafPages + stat.OverflowPages)
+	err = txn.verifyFreelist(r, info.LastPNO, latest)
+	if err != nil {
+		return nil, err
+	}
+	r.Pages = pages + numMetas + r.FreePages
+
+	if latest {
+		r.LastPNO = info.LastPNO
+		if r.Pages > info.LastPNO+1 || r.Exact && r.Pages != info.LastPNO+1 {
+			r.errorf("", nil, "%d pages accounted for but the last page is %d", r.Pages, info.LastPNO)
+		}
+	}
+	return r, nil
+}
+
+// verifyDB checks the order and number of items in dbi.  If dbi is the main
+// database the names of the named databases it contains are added to r.
+func (txn *Txn) verifyDB(r *VerifyReport, name string, dbi DBI) (*Stat, error) {
+	stat, err := txn.Stat(dbi)
+	if err != nil {
+		return nil, err
+	}
+	flags, err := txn.Flags(dbi)
+	if err != nil {
+		return nil, err
+	}
+	main := dbi == mainDBI && flags&(DupSort|IntegerKey) == 0
+	if flags&DupSort != 0 {
+		r.Exact = false
+	}
+
+	cur, err := txn.OpenCursor(dbi)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close()
+
+	var n uint64
+	var prevk, prevv []byte
+	for op := uint(First); ; op = Next {
+		err = cur.getVal0(op)
+		if IsNotFound(err) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		k := getBytes(txn.key)
+		v := getBytes(txn.val)
+		*txn.key = C.MDB_val{}
+		*txn.val = C.MDB_val{}
+
+		if n > 0 {
+			c := txn.cmp(dbi, prevk, k)
+			switch {
+			case c > 0:
+				r.errorf(name, k, "key out of order")
+			case c == 0 && flags&DupSort == 0:
+				r.errorf(name, k, "duplicate key")
+			case c == 0 && txn.dcmp(dbi, prevv, v) >= 0:
+				r.errorf(name, k, "duplicate value out of order")
+			}
+		}
+		n++
+		prevk, prevv = k, v
+
+		if main && len(k) > 0 && bytes.IndexByte(k, 0) < 0 {
+			_, err = txn.OpenDBI(string(k), 0)
+			if err == nil {
+				r.DBs = append(r.DBs, string(k))
+			} else if !IsErrno(err, Incompatible) {
+				return nil, err
+			}
+		}
+	}
+
+	r.Entries += n
+	if n != stat.Entries {
+		r.errorf(name, nil, "found %d items but the database contains %d", n, stat.Entries)
+	}
+	return stat, nil
+}
+
+// verifyFreelist checks that pages in the freelist are in order, are within
+// the data file, and are not listed more than once.  Verification of the
+// freelist stops at the first malformed record.
+func (txn *Txn) verifyFreelist(r *VerifyReport, lastPNO int64, latest bool) error {
+	seen := make(map[uint64]bool)
+	err := txn.Freelist(func(txnid int64, pages []uint64) error {
+		r.FreePages += int64(len(pages))
+		for i, pg := range pages {
+			if i > 0 && pg >= pages[i-1] {
+				r.errorf("", nil, "freelist: transaction %d: pages out of order", txnid)
+			}
+			if pg < numMetas || latest && int64(pg) > lastPNO {
+				r.errorf("", nil, "freelist: transaction %d: invalid page %d", txnid, pg)
+			}
+			if seen[pg] {
+				r.errorf("", nil, "freelist: transaction %d: page %d freed more than once", txnid, pg)
+			}
+			seen[pg] = true
+		}
+		return nil
+	})
+	if err == errFreelistCorrupted {
+		r.errorf("", nil, "freelist: %v", err)
+		return nil
+	}
+	return err
+}
+
+// cmp compares two keys of dbi.  The keys must reference memory owned by
+// LMDB.
+//
+// See mdb_cmp.
+func (txn *Txn) cmp(dbi DBI, a, b []byte) int {
+	ca, cb := cmpVal(a), cmpVal(b)
+	return int(C.mdb_cmp(txn._txn, C.MDB_dbi(dbi), &ca, &cb))
+}
+
+// dcmp compares two duplicate values of dbi.  The values must reference
+// memory owned by LMDB.
+//
+// See mdb_dcmp.
+func (txn *Txn) dcmp(dbi DBI, a, b []byte) int {
+	ca, cb := cmpVal(a), cmpVal(b)
+	return int(C.mdb_dcmp(txn._txn, C.MDB_dbi(dbi), &ca, &cb))
+}
+
+func cmpVal(b []byte) C.MDB_val {
+	if len(b) == 0 {
+		return C.MDB_val{}
+	}
+	return C.MDB_val{mv_size: C.size_t(len(b)), mv_data: unsafe.Pointer(&b[0])}
+}
+
+// Verify checks the consistency of the latest snapshot of env.  See
+// Txn.Verify.
+func (env *Env) Verify() (r *VerifyReport, err error) {
+	err = env.View(func(txn *Txn) (err error) {
+		r, err = txn.Verify()
+		return err
+	})
+	return r, err
+}
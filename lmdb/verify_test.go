@@ -0,0 +1,238 @@
+package lmdb
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTxn_Verify(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi1, err := openDBI(env, "db1", Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbi2, err := openDBI(env, "db2", Create|DupSort|ReverseKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.Update(func(txn *Txn) (err error) {
+		root, err := txn.OpenRoot(0)
+		if err != nil {
+			return err
+		}
+		err = txn.Put(root, []byte("plainkey"), []byte("v"), 0)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 1000; i++ {
+			k := []byte(fmt.Sprintf("k%04d", i))
+			err = txn.Put(dbi1, k, make([]byte, 100+i), 0)
+			if err != nil {
+				return err
+			}
+			err = txn.Put(dbi2, k[:3], k, 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// free some pages
+	err = env.Update(func(txn *Txn) (err error) {
+		for i := 0; i < 500; i++ {
+			err = txn.Del(dbi1, []byte(fmt.Sprintf("k%04d", i)), nil)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := env.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.OK() {
+		t.Errorf("unexpected errors: %v", r.Errors)
+	}
+	if len(r.DBs) != 2 || r.DBs[0] != "db1" || r.DBs[1] != "db2" {
+		t.Errorf("unexpected databases: %q", r.DBs)
+	}
+	if r.Entries != 3+500+1000 {
+		t.Errorf("unexpected entries: %d (!= %d)", r.Entries, 3+500+1000)
+	}
+	if r.FreePages == 0 {
+		t.Errorf("no free pages")
+	}
+	if r.LastPNO < 0 {
+		t.Errorf("page accounting was skipped")
+	}
+	if r.Exact {
+		t.Errorf("exact page accounting with a DupSort database")
+	}
+	if r.Pages > r.LastPNO+1 {
+		t.Errorf("unexpected pages: %d (> %d)", r.Pages, r.LastPNO+1)
+	}
+
+	// page accounting is skipped when the snapshot is not the latest.
+	txn, err := env.BeginTxn(nil, Readonly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	err = env.Update(func(txn *Txn) (err error) {
+		return txn.Put(dbi1, []byte("k"), []byte("v"), 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err = txn.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.OK() {
+		t.Errorf("unexpected errors: %v", r.Errors)
+	}
+	if r.LastPNO != -1 {
+		t.Errorf("unexpected last page: %d", r.LastPNO)
+	}
+}
+
+func TestTxn_Verify_exact(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	dbi, err := openDBI(env, "testdb", Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		err = env.Update(func(txn *Txn) (err error) {
+			for j := 0; j < 100; j++ {
+				k := []byte(fmt.Sprintf("k%04d", j))
+				if i%2 == 1 && j%2 == 0 {
+					err = txn.Del(dbi, k, nil)
+				} else {
+					// some values require overflow pages
+					err = txn.Put(dbi, k, make([]byte, 30*j+i), 0)
+				}
+				if err != nil && !IsNotFound(err) {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := env.Verify()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !r.OK() {
+			t.Errorf("unexpected errors: %v", r.Errors)
+		}
+		if !r.Exact {
+			t.Errorf("inexact page accounting")
+		}
+		if r.Pages != r.LastPNO+1 {
+			t.Errorf("unexpected pages: %d (!= %d)", r.Pages, r.LastPNO+1)
+		}
+	}
+}
+
+func TestTxn_Verify_cmp(t *testing.T) {
+	cmp, err := registerLengthCmp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := setup(t)
+	defer func() { clean(env, t) }()
+
+	// keys are written in the order of cmp, which is not the default order.
+	err = env.Update(func(txn *Txn) (err error) {
+		dbi, err := txn.OpenDBICmp("lencmp", Create, cmp, nil)
+		if err != nil {
+			return err
+		}
+		for _, k := range []string{"b", "aa", "ab"} {
+			err = txn.Put(dbi, []byte(k), []byte("v"), 0)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// reopen the environment so the database is verified without cmp.
+	path, err := env.Path()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err = NewEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.SetMaxDBs(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.Open(path, 0, 0664)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := env.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.OK() {
+		t.Fatalf("database with a custom comparison was verified")
+	}
+	if len(r.Errors) != 1 {
+		t.Fatalf("unexpected errors: %v", r.Errors)
+	}
+	verr := r.Errors[0]
+	if verr.DB != "lencmp" {
+		t.Errorf("unexpected database: %q (!= %q)", verr.DB, "lencmp")
+	}
+	if string(verr.Key) != "aa" {
+		t.Errorf("unexpected key: %q (!= %q)", verr.Key, "aa")
+	}
+	if verr.Msg != "key out of order" {
+		t.Errorf("unexpected message: %q", verr.Msg)
+	}
+}
+
+func TestVerifyError(t *testing.T) {
+	for _, test := range []struct {
+		err *VerifyError
+		s   string
+	}{
+		{&VerifyError{Msg: "bad"}, "bad"},
+		{&VerifyError{DB: "db", Msg: "bad"}, `database "db": bad`},
+		{&VerifyError{DB: "db", Key: []byte("k"), Msg: "bad"}, `database "db": key "k": bad`},
+	} {
+		if test.err.Error() != test.s {
+			t.Errorf("unexpected message: %q (!= %q)", test.err.Error(), test.s)
+		}
+	}
+}
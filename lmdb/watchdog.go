@@ -0,0 +1,311 @@
+package lmdb
+
+import (
+	"errors"
+	"log"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var errWatchdogRunning = errors.New("environment already has a watchdog")
+
+// WatchdogOptions configures a Watchdog.
+type WatchdogOptions struct {
+	// Interval is the time between checks of the reader table.  The default
+	// interval is 10s.
+	Interval time.Duration
+
+	// MaxAge is the age at which a read transaction in this process is
+	// reported.  The age of a transaction is measured from when it was begun
+	// or last renewed.  If MaxAge is zero transactions are not reported
+	// because of their age.
+	MaxAge time.Duration
+
+	// MaxLag is the number of transactions which may be committed after the
+	// snapshot viewed by a reader before it is reported.  If MaxLag is zero
+	// readers are not reported because of their lag.
+	MaxLag int64
+
+	// Stacks causes the stack of the goroutine beginning each read
+	// transaction to be recorded so that it can be reported.  Recording
+	// stacks makes beginning a transaction considerably more expensive.
+	Stacks bool
+
+	// Report is called from the watchdog's goroutine with each reader which
+	// exceeds MaxAge or MaxLag.  A reader is reported after every check
+	// until it terminates.  If Report is nil readers are reported using the
+	// log package.
+	Report func(r *LongReader)
+
+	// Cleared is called after each check with the result of
+	// Env.ReaderCheck.  If Cleared is nil stale readers which were cleared,
+	// and any error, are reported using the log package.
+	Cleared func(numDead int, err error)
+}
+
+// LongReader describes a reader which exceeded a limit set in
+// WatchdogOptions.
+type LongReader struct {
+	PID   int
+	TxnID int64
+	Lag   int64
+
+	// Age and Stack are only known for transactions begun by this process
+	// after the watchdog was started.  Stack is nil unless
+	// WatchdogOptions.Stacks is true.
+	Age   time.Duration
+	Stack []byte
+}
+
+// Watchdog periodically clears stale readers from the lock table of an Env
+// and reports long-lived and lagging readers.  Read transactions which are
+// never terminated keep pages from being reused by writers, causing the
+// database file to grow without bound.
+//
+// Read transactions begun by this process are tracked from the time the
+// Watchdog is started, and are reported if they exceed either
+// WatchdogOptions.MaxAge or WatchdogOptions.MaxLag.  Readers from other
+// processes, and readers in this process which are not tracked, are found
+// using Env.Readers and may only be reported for their lag.
+type Watchdog struct {
+	env  *Env
+	opt  WatchdogOptions
+	pid  int
+	done chan struct{}
+	wg   sync.WaitGroup
+	once sync.Once
+}
+
+// StartWatchdog begins watching the readers of env.  An Env may only have one
+// Watchdog at a time.  The Close method must be called before env is closed.
+func (env *Env) StartWatchdog(opt *WatchdogOptions) (*Watchdog, error) {
+	w := &Watchdog{
+		env:  env,
+		pid:  os.Getpid(),
+		done: make(chan struct{}),
+	}
+	if opt != nil {
+		w.opt = *opt
+	}
+	if w.opt.Interval <= 0 {
+		w.opt.Interval = 10 * time.Second
+	}
+	if !env.readers.start(w.opt.Stacks) {
+		return nil, errWatchdogRunning
+	}
+	w.wg.Add(1)
+	go w.loop()
+	return w, nil
+}
+
+// Close stops w.  Transactions are no longer tracked after Close returns.
+func (w *Watchdog) Close() {
+	w.once.Do(func() {
+		close(w.done)
+		w.wg.Wait()
+		w.env.readers.stop()
+	})
+}
+
+func (w *Watchdog) loop() {
+	defer w.wg.Done()
+	t := time.NewTicker(w.opt.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-t.C:
+			w.Check()
+		}
+	}
+}
+
+// Check clears stale readers and reports long-lived and lagging readers
+// immediately.  Check is called periodically by w and need not be called by
+// the application.
+func (w *Watchdog) Check() {
+	dead, err := w.env.ReaderCheck()
+	if w.opt.Cleared != nil {
+		w.opt.Cleared(dead, err)
+	} else if err != nil {
+		log.Printf("lmdb: reader check failed: %v", err)
+	} else if dead > 0 {
+		log.Printf("lmdb: cleared %d stale readers", dead)
+	}
+
+	for _, r := range w.longReaders() {
+		w.report(r)
+	}
+}
+
+func (w *Watchdog) longReaders() []*LongReader {
+	info, err := w.env.Info()
+	if err != nil {
+		return nil
+	}
+	now := time.Now()
+	var long []*LongReader
+	tracked := make(map[int64]int)
+	for _, t := range w.env.readers.list() {
+		tracked[t.id]++
+		r := &LongReader{
+			PID:   w.pid,
+			TxnID: t.id,
+			Lag:   info.LastTxnID - t.id,
+			Age:   now.Sub(t.begun),
+			Stack: t.stack,
+		}
+		if w.exceeds(r) {
+			long = append(long, r)
+		}
+	}
+	if w.opt.MaxLag <= 0 {
+		return long
+	}
+	readers, err := w.env.Readers()
+	if err != nil {
+		return long
+	}
+	for i := range readers {
+		if !readers[i].Active() {
+			continue
+		}
+		// Readers in this process which are tracked have been checked
+		// above.  Untracked readers, such as those begun before w was
+		// started, are only found in the reader table.
+		if readers[i].PID == w.pid && tracked[readers[i].TxnID] > 0 {
+			tracked[readers[i].TxnID]--
+			continue
+		}
+		r := &LongReader{
+			PID:   readers[i].PID,
+			TxnID: readers[i].TxnID,
+			Lag:   readers[i].Lag(info.LastTxnID),
+		}
+		if w.exceeds(r) {
+			long = append(long, r)
+		}
+	}
+	return long
+}
+
+func (w *Watchdog) exceeds(r *LongReader) bool {
+	if w.opt.MaxAge > 0 && r.Age > w.opt.MaxAge {
+		return true
+	}
+	return w.opt.MaxLag > 0 && r.Lag > w.opt.MaxLag
+}
+
+func (w *Watchdog) report(r *LongReader) {
+	if w.opt.Report != nil {
+		w.opt.Report(r)
+		return
+	}
+	if r.PID != w.pid || r.Age == 0 {
+		log.Printf("lmdb: reader in process %d is %d transactions behind (txnid %d)", r.PID, r.Lag, r.TxnID)
+		return
+	}
+	log.Printf("lmdb: reader open for %v is %d transactions behind (txnid %d)", r.Age, r.Lag, r.TxnID)
+	if r.Stack != nil {
+		log.Printf("lmdb: reader begun at:\n%s", r.Stack)
+	}
+}
+
+// readerTracker records the read transactions begun by an Env while it has a
+// Watchdog.  Transactions are identified by a sequence number instead of a
+// pointer so that leaked transactions remain unreachable and can be
+// finalized.
+type readerTracker struct {
+	on     int32 // accessed atomically
+	stacks bool
+	mut    sync.Mutex
+	seq    uint64
+	txns   map[uint64]*trackedReader
+}
+
+type trackedReader struct {
+	id    int64
+	begun time.Time
+	stack []byte
+}
+
+func (t *readerTracker) start(stacks bool) bool {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	if t.txns != nil {
+		return false
+	}
+	t.txns = make(map[uint64]*trackedReader)
+	t.stacks = stacks
+	atomic.StoreInt32(&t.on, 1)
+	return true
+}
+
+func (t *readerTracker) stop() {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	atomic.StoreInt32(&t.on, 0)
+	t.txns = nil
+}
+
+func (t *readerTracker) list() []*trackedReader {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	list := make([]*trackedReader, 0, len(t.txns))
+	for _, r := range t.txns {
+		list = append(list, r)
+	}
+	return list
+}
+
+// track begins tracking txn, or updates its entry after it has been renewed.
+func (txn *Txn) track() {
+	t := &txn.env.readers
+	if atomic.LoadInt32(&t.on) == 0 {
+		return
+	}
+	r := &trackedReader{
+		id:    int64(txn.ID()),
+		begun: time.Now(),
+	}
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	if t.txns == nil {
+		return
+	}
+	if t.stacks {
+		r.stack = stack()
+	}
+	if txn.trackID == 0 {
+		t.seq++
+		txn.trackID = t.seq
+	}
+	t.txns[txn.trackID] = r
+}
+
+// untrack stops tracking txn after it has been reset or terminated.
+func (txn *Txn) untrack() {
+	if txn.trackID == 0 {
+		return
+	}
+	t := &txn.env.readers
+	t.mut.Lock()
+	delete(t.txns, txn.trackID)
+	t.mut.Unlock()
+	txn.trackID = 0
+}
+
+func stack() []byte {
+	buf := make([]byte, 4096)
+	for {
+		n := runtime.Stack(buf, false)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
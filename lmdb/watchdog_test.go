@@ -0,0 +1,204 @@
+package lmdb
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestWatchdog(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	var long []*LongReader
+	var cleared int
+	w, err := env.StartWatchdog(&WatchdogOptions{
+		Interval: time.Hour,
+		MaxLag:   2,
+		Stacks:   true,
+		Report:   func(r *LongReader) { long = append(long, r) },
+		Cleared:  func(n int, err error) { cleared++ },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	_, err = env.StartWatchdog(nil)
+	if err != errWatchdogRunning {
+		t.Errorf("unexpected error: %v (!= %v)", err, errWatchdogRunning)
+	}
+
+	txn, err := env.BeginTxn(nil, Readonly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+
+	update := func() {
+		err := env.Update(func(txn *Txn) (err error) {
+			dbi, err := txn.OpenRoot(0)
+			if err != nil {
+				return err
+			}
+			return txn.Put(dbi, []byte("k"), []byte("v"), 0)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	update()
+	update()
+
+	w.Check()
+	if cleared != 1 {
+		t.Errorf("unexpected number of reader checks: %d (!= 1)", cleared)
+	}
+	if len(long) != 0 {
+		t.Errorf("unexpected long readers: %v", long)
+	}
+
+	update()
+	w.Check()
+	if len(long) != 1 {
+		t.Fatalf("unexpected long readers: %v", long)
+	}
+	if long[0].Lag != 3 {
+		t.Errorf("unexpected lag: %d (!= 3)", long[0].Lag)
+	}
+	if long[0].TxnID != int64(txn.ID()) {
+		t.Errorf("unexpected txnid: %d (!= %d)", long[0].TxnID, txn.ID())
+	}
+	if !bytes.Contains(long[0].Stack, []byte("TestWatchdog")) {
+		t.Errorf("unexpected stack: %s", long[0].Stack)
+	}
+
+	// a renewed transaction views the latest snapshot.
+	long = nil
+	txn.Reset()
+	w.Check()
+	if len(long) != 0 {
+		t.Errorf("unexpected long readers: %v", long)
+	}
+	err = txn.Renew()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Check()
+	if len(long) != 0 {
+		t.Errorf("unexpected long readers: %v", long)
+	}
+}
+
+func TestWatchdog_untracked(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	// A transaction begun before the watchdog is started is not tracked.
+	txn, err := env.BeginTxn(nil, Readonly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+
+	var long []*LongReader
+	w, err := env.StartWatchdog(&WatchdogOptions{
+		Interval: time.Hour,
+		MaxLag:   1,
+		Report:   func(r *LongReader) { long = append(long, r) },
+		Cleared:  func(n int, err error) {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	tracked, err := env.BeginTxn(nil, Readonly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tracked.Abort()
+
+	for i := 0; i < 2; i++ {
+		err = env.Update(func(txn *Txn) (err error) {
+			dbi, err := txn.OpenRoot(0)
+			if err != nil {
+				return err
+			}
+			return txn.Put(dbi, []byte("k"), []byte("v"), 0)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Both readers view the same snapshot but each is reported once.
+	w.Check()
+	if len(long) != 2 {
+		t.Fatalf("unexpected long readers: %v", long)
+	}
+	var untracked int
+	for _, r := range long {
+		if r.PID != w.pid || r.TxnID != int64(txn.ID()) || r.Lag != 2 {
+			t.Errorf("unexpected long reader: %+v", r)
+		}
+		if r.Age == 0 {
+			untracked++
+		}
+	}
+	if untracked != 1 {
+		t.Errorf("unexpected untracked readers: %d (!= 1)", untracked)
+	}
+}
+
+func TestWatchdog_maxAge(t *testing.T) {
+	env := setup(t)
+	defer clean(env, t)
+
+	var long []*LongReader
+	w, err := env.StartWatchdog(&WatchdogOptions{
+		Interval: time.Hour,
+		MaxAge:   time.Millisecond,
+		Report:   func(r *LongReader) { long = append(long, r) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.View(func(txn *Txn) (err error) {
+		time.Sleep(10 * time.Millisecond)
+		w.Check()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(long) != 1 {
+		t.Fatalf("unexpected long readers: %v", long)
+	}
+	if long[0].Age < 10*time.Millisecond {
+		t.Errorf("unexpected age: %v", long[0].Age)
+	}
+	if long[0].Stack != nil {
+		t.Errorf("unexpected stack: %s", long[0].Stack)
+	}
+
+	// terminated transactions are not reported.
+	long = nil
+	w.Check()
+	if len(long) != 0 {
+		t.Errorf("unexpected long readers: %v", long)
+	}
+
+	// transactions are not tracked after the watchdog is closed.
+	w.Close()
+	err = env.View(func(txn *Txn) (err error) {
+		if txn.trackID != 0 {
+			t.Errorf("transaction tracked after close")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
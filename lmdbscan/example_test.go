@@ -51,6 +51,29 @@ func ExampleScanner_Set() {
 	}
 }
 
+// This example demonstrates scanning the ten most recent events for a user
+// using a RangeScanner.  The Range handles the bounds of the prefix and the
+// reverse iteration which would otherwise need to be coded by hand using
+// Scanner.Set.
+func ExampleRangeScanner() {
+	err := env.View(func(txn *lmdb.Txn) (err error) {
+		scanner := lmdbscan.NewRange(txn, dbi, &lmdbscan.Range{
+			Prefix:  []byte("events:123:"),
+			Reverse: true,
+			Limit:   10,
+		})
+		defer scanner.Close()
+
+		for scanner.Scan() {
+			log.Printf("k=%q v=%q", scanner.Key(), scanner.Val())
+		}
+		return scanner.Err()
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
 // This example demonstrates scanning all values for a key in a root database
 // with the lmdb.DupSort flag set.  SetNext is used instead of Set to configure
 // Cursor the to return ErrNotFound (EOF) after all duplicate keys have been
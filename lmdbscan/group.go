@@ -0,0 +1,195 @@
+package lmdbscan
+
+import (
+	"github.com/bmatsuo/lmdb-go/lmdb"
+)
+
+// GroupScanner scans the distinct keys of a database and, for each key, its
+// values.  GroupScanner is intended for databases with the lmdb.DupSort flag,
+// where it replaces the manual use of NextNoDup and NextDup, but it may scan
+// any database.
+//
+//	for s.Scan() {
+//		log.Printf("k=%q (%d values)", s.Key(), s.Count())
+//		for s.ScanVal() {
+//			log.Printf("v=%q", s.Val())
+//		}
+//	}
+//	err := s.Err()
+//
+// In a database with the lmdb.DupFixed flag ScanMulti and Multi retrieve the
+// values of a key a page at a time using lmdb.GetMultiple and
+// lmdb.NextMultiple.  ScanVal and ScanMulti may not both be used to scan the
+// values of the same key.
+type GroupScanner struct {
+	cur     *lmdb.Cursor
+	dup     bool
+	started bool
+	key     []byte
+	first   []byte
+	val     []byte
+	multi   *lmdb.Multi
+	count   uint64
+	nval    int // number of calls to ScanVal or ScanMulti for the key
+	err     error
+}
+
+// NewGroup allocates and initializes a GroupScanner for dbi within txn.  When
+// the GroupScanner is no longer needed its Close method must be called.
+func NewGroup(txn *lmdb.Txn, dbi lmdb.DBI) *GroupScanner {
+	s := &GroupScanner{}
+	var flags uint
+	flags, s.err = txn.Flags(dbi)
+	if s.err != nil {
+		return s
+	}
+	s.dup = flags&lmdb.DupSort != 0
+	s.cur, s.err = txn.OpenCursor(dbi)
+	return s
+}
+
+// Cursor returns the lmdb.Cursor underlying s.  Cursor returns nil if s is
+// closed.
+func (s *GroupScanner) Cursor() *lmdb.Cursor {
+	return s.cur
+}
+
+// Scan advances s to the next distinct key in the database, skipping any
+// values of the current key which have not been scanned.  Scan returns false
+// when the keys are exhausted or an error is encountered.
+func (s *GroupScanner) Scan() bool {
+	if !s.checkOpen() || s.Err() != nil {
+		return false
+	}
+	op := uint(lmdb.NextNoDup)
+	if !s.started {
+		op = lmdb.First
+		s.started = true
+	}
+	s.val, s.multi, s.nval = nil, nil, 0
+	s.key, s.first, s.err = s.cur.Get(nil, nil, op)
+	if s.err != nil {
+		s.key, s.first = nil, nil
+		return false
+	}
+	s.count = 1
+	if s.dup {
+		s.count, s.err = s.cur.Count()
+	}
+	return s.err == nil
+}
+
+// Key returns the key read during the last call to Scan.
+func (s *GroupScanner) Key() []byte {
+	return s.key
+}
+
+// Count returns the number of values of the current key.
+//
+// See mdb_cursor_count.
+func (s *GroupScanner) Count() uint64 {
+	return s.count
+}
+
+// ScanVal advances to the next value of the current key.  ScanVal returns
+// false when the values of the key are exhausted or an error is encountered.
+func (s *GroupScanner) ScanVal() bool {
+	if s.key == nil {
+		return false
+	}
+	s.nval++
+	if s.nval == 1 {
+		s.val = s.first
+		return true
+	}
+	if !s.dup {
+		s.val = nil
+		return false
+	}
+	var err error
+	_, s.val, err = s.cur.Get(nil, nil, lmdb.NextDup)
+	return s.checkVal(err)
+}
+
+// Val returns the value read during the last call to ScanVal.
+func (s *GroupScanner) Val() []byte {
+	return s.val
+}
+
+// ScanMulti advances to the next page of values of the current key in a
+// database with the lmdb.DupFixed flag.  ScanMulti returns false when the
+// values of the key are exhausted or an error is encountered.
+func (s *GroupScanner) ScanMulti() bool {
+	if s.key == nil {
+		return false
+	}
+	s.nval++
+	if s.count == 1 {
+		// GetMultiple does not return values for a key with one value.
+		if s.nval > 1 {
+			s.multi = nil
+			return false
+		}
+		s.multi = lmdb.WrapMulti(s.first, len(s.first))
+		return true
+	}
+	op := uint(lmdb.NextMultiple)
+	if s.nval == 1 {
+		op = lmdb.GetMultiple
+	}
+	_, page, err := s.cur.Get(nil, nil, op)
+	s.multi = nil
+	if s.checkVal(err) {
+		s.multi = lmdb.WrapMulti(page, len(s.first))
+		return true
+	}
+	return false
+}
+
+// Multi returns the page of values read during the last call to ScanMulti.
+func (s *GroupScanner) Multi() *lmdb.Multi {
+	return s.multi
+}
+
+// checkVal records an error encountered reading the values of a key.  The end
+// of the values is not an error.
+func (s *GroupScanner) checkVal(err error) bool {
+	if err == nil {
+		return true
+	}
+	if !lmdb.IsNotFound(err) {
+		s.err = err
+	}
+	s.val = nil
+	return false
+}
+
+func (s *GroupScanner) checkOpen() bool {
+	if s.cur != nil {
+		return true
+	}
+	if s.err == nil {
+		s.err = errClosed
+	}
+	return false
+}
+
+// Err returns a non-nil error if and only if a previous call to Scan,
+// ScanVal, or ScanMulti resulted in an error other than lmdb.ErrNotFound.
+func (s *GroupScanner) Err() error {
+	if lmdb.IsNotFound(s.err) {
+		return nil
+	}
+	return s.err
+}
+
+// Close closes the cursor underlying s.  Close does not attempt to terminate
+// the enclosing transaction.
+//
+// Scan must not be called after Close.
+func (s *GroupScanner) Close() {
+	if s.cur != nil {
+		s.cur.Close()
+		s.cur = nil
+	}
+}
@@ -0,0 +1,167 @@
+package lmdbscan
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/bmatsuo/lmdb-go/internal/lmdbtest"
+	"github.com/bmatsuo/lmdb-go/lmdb"
+)
+
+func TestGroupScanner(t *testing.T) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenDBI(env, "fixed", lmdb.Create|lmdb.DupSort|lmdb.DupFixed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// key "b" has enough values to span several pages.
+	counts := map[string]int{"a": 1, "b": 2000, "c": 3}
+	keys := []string{"a", "b", "c"}
+	vals := make(map[string][][]byte)
+	err = env.Update(func(txn *lmdb.Txn) (err error) {
+		for _, k := range keys {
+			for i := 0; i < counts[k]; i++ {
+				v := make([]byte, 8)
+				binary.BigEndian.PutUint64(v, uint64(i))
+				vals[k] = append(vals[k], v)
+				err = txn.Put(dbi, []byte(k), v, 0)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		// scan values one at a time, skipping the values of "b".
+		s := NewGroup(txn, dbi)
+		defer s.Close()
+		var scanned []string
+		for s.Scan() {
+			k := string(s.Key())
+			scanned = append(scanned, k)
+			if s.Count() != uint64(counts[k]) {
+				t.Errorf("%q: unexpected count: %d (!= %d)", k, s.Count(), counts[k])
+			}
+			if k == "b" {
+				continue
+			}
+			var v [][]byte
+			for s.ScanVal() {
+				v = append(v, s.Val())
+			}
+			if !reflect.DeepEqual(v, vals[k]) {
+				t.Errorf("%q: unexpected values: %q (!= %q)", k, v, vals[k])
+			}
+		}
+		if !reflect.DeepEqual(scanned, keys) {
+			t.Errorf("unexpected keys: %q (!= %q)", scanned, keys)
+		}
+		if s.Err() != nil {
+			return s.Err()
+		}
+
+		// scan values a page at a time.
+		s = NewGroup(txn, dbi)
+		defer s.Close()
+		for s.Scan() {
+			k := string(s.Key())
+			var v [][]byte
+			var pages int
+			for s.ScanMulti() {
+				pages++
+				v = append(v, s.Multi().Vals()...)
+			}
+			if !reflect.DeepEqual(v, vals[k]) {
+				t.Errorf("%q: unexpected values: %d (!= %d)", k, len(v), len(vals[k]))
+			}
+			if k == "b" && pages < 2 {
+				t.Errorf("%q: unexpected number of pages: %d", k, pages)
+			}
+		}
+		return s.Err()
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGroupScanner_noDup(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := lmdbtest.SimpleItemList{
+		{K: "k1", V: "v1"},
+		{K: "k2", V: "v2"},
+	}
+	err = lmdbtest.Put(env, dbi, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var scanned lmdbtest.SimpleItemList
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		s := NewGroup(txn, dbi)
+		defer s.Close()
+		for s.Scan() {
+			if s.Count() != 1 {
+				t.Errorf("unexpected count: %d (!= 1)", s.Count())
+			}
+			for s.ScanVal() {
+				scanned = append(scanned, &lmdbtest.SimpleItem{
+					K: string(s.Key()),
+					V: string(s.Val()),
+				})
+			}
+		}
+		return s.Err()
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(scanned, items) {
+		t.Errorf("unexpected items: %v (!= %v)", scanned, items)
+	}
+}
+
+func TestGroupScanner_closed(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		dbi, err := txn.OpenRoot(0)
+		if err != nil {
+			return err
+		}
+		s := NewGroup(txn, dbi)
+		s.Close()
+		for s.Scan() {
+			t.Error("loop should not execute")
+		}
+		return s.Err()
+	})
+	if err != errClosed {
+		t.Errorf("unexpected error: %v (!= %v)", err, errClosed)
+	}
+}
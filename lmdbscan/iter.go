@@ -0,0 +1,132 @@
+//go:build go1.23
+// +build go1.23
+
+package lmdbscan
+
+import (
+	"iter"
+
+	"github.com/bmatsuo/lmdb-go/lmdb"
+)
+
+// Iter provides iterators over the items of a database for use with range
+// statements.  Each iterator opens a cursor when a loop begins and closes it
+// when the loop terminates, including when the loop exits early.  Any error
+// which stops an iteration is available from the Err method after the loop.
+//
+//	it := lmdbscan.NewIter(txn, dbi)
+//	for k, v := range it.All() {
+//		// ...
+//	}
+//	if it.Err() != nil {
+//		// ...
+//	}
+//
+// The slices produced by an iterator are subject to the same restrictions as
+// those returned by lmdb.Cursor.Get.  In particular, if txn.RawRead is true
+// they must not be retained after the transaction terminates.  An Iter must
+// not be used after its transaction terminates.
+type Iter struct {
+	txn *lmdb.Txn
+	dbi lmdb.DBI
+	err error
+}
+
+// NewIter returns an Iter for dbi within txn.
+func NewIter(txn *lmdb.Txn, dbi lmdb.DBI) *Iter {
+	return &Iter{txn: txn, dbi: dbi}
+}
+
+// Err returns the error which stopped the most recent iteration, if any.
+// Reaching the end of the items being iterated is not an error.
+func (it *Iter) Err() error {
+	return it.err
+}
+
+// All returns an iterator over every item in the database.
+func (it *Iter) All() iter.Seq2[[]byte, []byte] {
+	return it.Range(nil)
+}
+
+// Prefix returns an iterator over the items whose keys begin with prefix.
+func (it *Iter) Prefix(prefix []byte) iter.Seq2[[]byte, []byte] {
+	return it.Range(&Range{Prefix: prefix})
+}
+
+// Range returns an iterator over the items contained in r.  See Range and
+// NewRange.
+func (it *Iter) Range(r *Range) iter.Seq2[[]byte, []byte] {
+	return func(yield func(k, v []byte) bool) {
+		s := NewRange(it.txn, it.dbi, r)
+		defer s.Close()
+		it.err = nil
+		for s.Scan() {
+			if !yield(s.Key(), s.Val()) {
+				return
+			}
+		}
+		it.err = s.Err()
+	}
+}
+
+// Dups returns an iterator over the values of key, in order, in a database
+// with the lmdb.DupSort flag.  If the database does not contain key the
+// iterator produces no values.
+func (it *Iter) Dups(key []byte) iter.Seq[[]byte] {
+	return func(yield func(v []byte) bool) {
+		it.scan(key, lmdb.Set, lmdb.NextDup, func(s *Scanner) bool {
+			return yield(s.Val())
+		})
+	}
+}
+
+// Keys returns an iterator over the distinct keys in a database.  In a
+// database with the lmdb.DupSort flag each key is produced once regardless
+// of the number of values it has.
+func (it *Iter) Keys() iter.Seq[[]byte] {
+	return func(yield func(k []byte) bool) {
+		it.scan(nil, lmdb.First, lmdb.NextNoDup, func(s *Scanner) bool {
+			return yield(s.Key())
+		})
+	}
+}
+
+// Groups returns an iterator over the distinct keys in a database, each
+// paired with an iterator over its values.  The iterator over the values of a
+// key may only be used once, before the loop over the keys advances.  See
+// GroupScanner.
+func (it *Iter) Groups() iter.Seq2[[]byte, iter.Seq[[]byte]] {
+	return func(yield func(k []byte, vals iter.Seq[[]byte]) bool) {
+		s := NewGroup(it.txn, it.dbi)
+		defer s.Close()
+		it.err = nil
+		vals := func(yield func(v []byte) bool) {
+			for s.ScanVal() {
+				if !yield(s.Val()) {
+					return
+				}
+			}
+		}
+		for s.Scan() {
+			if !yield(s.Key(), vals) {
+				return
+			}
+		}
+		it.err = s.Err()
+	}
+}
+
+// scan calls fn with a Scanner positioned at each item found by
+// s.SetNext(k, nil, opset, opnext) until fn returns false.
+func (it *Iter) scan(k []byte, opset, opnext uint, fn func(s *Scanner) bool) {
+	s := New(it.txn, it.dbi)
+	defer s.Close()
+	it.err = nil
+	s.SetNext(k, nil, opset, opnext)
+	for s.Scan() {
+		if !fn(s) {
+			return
+		}
+	}
+	it.err = s.Err()
+}
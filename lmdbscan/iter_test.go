@@ -0,0 +1,143 @@
+//go:build go1.23
+// +build go1.23
+
+package lmdbscan
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bmatsuo/lmdb-go/internal/lmdbtest"
+	"github.com/bmatsuo/lmdb-go/lmdb"
+)
+
+func TestIter(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenRoot(env, lmdb.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := lmdbtest.SimpleItemList{
+		{K: "a1", V: "x"},
+		{K: "a1", V: "y"},
+		{K: "a2", V: "x"},
+		{K: "b1", V: "x"},
+		{K: "b1", V: "y"},
+		{K: "b1", V: "z"},
+		{K: "c1", V: "x"},
+	}
+	err = lmdbtest.Put(env, dbi, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		it := NewIter(txn, dbi)
+
+		var all lmdbtest.SimpleItemList
+		for k, v := range it.All() {
+			all = append(all, &lmdbtest.SimpleItem{K: string(k), V: string(v)})
+		}
+		if it.Err() != nil {
+			return it.Err()
+		}
+		if !reflect.DeepEqual(all, items) {
+			t.Errorf("unexpected items: %v (!= %v)", all, items)
+		}
+
+		var prefix []string
+		for k, v := range it.Prefix([]byte("b")) {
+			prefix = append(prefix, string(k)+"="+string(v))
+		}
+		exp := []string{"b1=x", "b1=y", "b1=z"}
+		if !reflect.DeepEqual(prefix, exp) {
+			t.Errorf("unexpected items: %q (!= %q)", prefix, exp)
+		}
+
+		var rev []string
+		for k := range it.Range(&Range{Start: []byte("a2"), End: []byte("b1"), IncludeEnd: true, Reverse: true}) {
+			rev = append(rev, string(k))
+		}
+		exp = []string{"b1", "b1", "b1", "a2"}
+		if !reflect.DeepEqual(rev, exp) {
+			t.Errorf("unexpected keys: %q (!= %q)", rev, exp)
+		}
+
+		var dups []string
+		for v := range it.Dups([]byte("b1")) {
+			dups = append(dups, string(v))
+		}
+		exp = []string{"x", "y", "z"}
+		if !reflect.DeepEqual(dups, exp) {
+			t.Errorf("unexpected values: %q (!= %q)", dups, exp)
+		}
+		for v := range it.Dups([]byte("b2")) {
+			t.Errorf("unexpected value: %q", v)
+		}
+
+		groups := make(map[string][]string)
+		for k, vals := range it.Groups() {
+			for v := range vals {
+				groups[string(k)] = append(groups[string(k)], string(v))
+			}
+		}
+		expgroups := map[string][]string{
+			"a1": {"x", "y"},
+			"a2": {"x"},
+			"b1": {"x", "y", "z"},
+			"c1": {"x"},
+		}
+		if !reflect.DeepEqual(groups, expgroups) {
+			t.Errorf("unexpected groups: %q (!= %q)", groups, expgroups)
+		}
+
+		var keys []string
+		for k := range it.Keys() {
+			keys = append(keys, string(k))
+			if len(keys) == 3 {
+				break
+			}
+		}
+		exp = []string{"a1", "a2", "b1"}
+		if !reflect.DeepEqual(keys, exp) {
+			t.Errorf("unexpected keys: %q (!= %q)", keys, exp)
+		}
+		return it.Err()
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIter_err(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		it := NewIter(txn, 123)
+		for range it.All() {
+			t.Error("loop should not execute")
+		}
+		if it.Err() == nil {
+			t.Error("expected an error")
+		}
+		for range it.Keys() {
+			t.Error("loop should not execute")
+		}
+		if it.Err() == nil {
+			t.Error("expected an error")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,261 @@
+package lmdbscan
+
+import (
+	"bytes"
+	"container/heap"
+)
+
+// Source is an ordered sequence of items.  Scanner, RangeScanner, Merge, and
+// Join are all Sources, so the items of any database (in any environment) may
+// be merged or joined.  The sources passed to Merge and Join must be ordered
+// consistently with the comparison function they are given.
+type Source interface {
+	Scan() bool
+	Key() []byte
+	Val() []byte
+	Err() error
+}
+
+// Merge scans the items of several Sources in key order without reading
+// them into memory.  Items with equal keys are produced in the order of their
+// sources.  The union of the keys in the sources can be computed by skipping
+// items whose key equals the previous key.
+//
+// Merge does not close its sources, which remain the responsibility of the
+// caller.
+type Merge struct {
+	h       mergeHeap
+	started bool
+	cur     int
+	err     error
+}
+
+// NewMerge returns a Merge of srcs, which are all ordered by cmp.  If cmp is
+// nil bytes.Compare is used.
+func NewMerge(cmp func(a, b []byte) int, srcs ...Source) *Merge {
+	if cmp == nil {
+		cmp = bytes.Compare
+	}
+	return &Merge{
+		h:   mergeHeap{srcs: srcs, cmp: cmp},
+		cur: -1,
+	}
+}
+
+// Scan advances m to the next item in key order.  Scan returns false when
+// the items of all sources are exhausted or any source encounters an error.
+func (m *Merge) Scan() bool {
+	if m.err != nil {
+		return false
+	}
+	if !m.started {
+		m.started = true
+		for i, src := range m.h.srcs {
+			if src.Scan() {
+				m.h.idx = append(m.h.idx, i)
+			} else if m.fail(src) {
+				return false
+			}
+		}
+		heap.Init(&m.h)
+	} else if m.cur >= 0 {
+		// The source of the current item is at the root of the heap.
+		src := m.h.srcs[m.cur]
+		if src.Scan() {
+			heap.Fix(&m.h, 0)
+		} else if m.fail(src) {
+			return false
+		} else {
+			heap.Pop(&m.h)
+		}
+	}
+	if len(m.h.idx) == 0 {
+		m.cur = -1
+		return false
+	}
+	m.cur = m.h.idx[0]
+	return true
+}
+
+func (m *Merge) fail(src Source) bool {
+	m.err = src.Err()
+	if m.err != nil {
+		m.cur = -1
+		return true
+	}
+	return false
+}
+
+// Key returns the key of the current item.
+func (m *Merge) Key() []byte {
+	if m.cur < 0 {
+		return nil
+	}
+	return m.h.srcs[m.cur].Key()
+}
+
+// Val returns the value of the current item.
+func (m *Merge) Val() []byte {
+	if m.cur < 0 {
+		return nil
+	}
+	return m.h.srcs[m.cur].Val()
+}
+
+// Source returns the index of the source of the current item in the
+// arguments to NewMerge, or -1 if there is no current item.
+func (m *Merge) Source() int {
+	return m.cur
+}
+
+// Err returns the first error encountered by any source.
+func (m *Merge) Err() error {
+	return m.err
+}
+
+// mergeHeap orders source indexes by the current key of each source.
+type mergeHeap struct {
+	srcs []Source
+	idx  []int
+	cmp  func(a, b []byte) int
+}
+
+func (h *mergeHeap) Len() int { return len(h.idx) }
+
+func (h *mergeHeap) Less(i, j int) bool {
+	c := h.cmp(h.srcs[h.idx[i]].Key(), h.srcs[h.idx[j]].Key())
+	if c != 0 {
+		return c < 0
+	}
+	return h.idx[i] < h.idx[j]
+}
+
+func (h *mergeHeap) Swap(i, j int) { h.idx[i], h.idx[j] = h.idx[j], h.idx[i] }
+
+func (h *mergeHeap) Push(x interface{}) { h.idx = append(h.idx, x.(int)) }
+
+func (h *mergeHeap) Pop() interface{} {
+	n := len(h.idx)
+	x := h.idx[n-1]
+	h.idx = h.idx[:n-1]
+	return x
+}
+
+// Join scans the items of a left Source paired with the items of a right
+// Source having equal keys, in the manner of a sort-merge join.  Both sources
+// are scanned once, without reading them into memory.  The left source may
+// contain a key more than once but only the first item for each key in the
+// right source is joined.
+//
+// Join does not close its sources, which remain the responsibility of the
+// caller.
+type Join struct {
+	left, right Source
+	cmp         func(a, b []byte) int
+	outer       bool
+	rstarted    bool
+	rok         bool
+	match       bool
+	err         error
+}
+
+// InnerJoin returns a Join which produces the items of left which have a key
+// in right.  Both sources must be ordered by cmp.  If cmp is nil
+// bytes.Compare is used.  The intersection of the keys in two sources can be
+// computed with an InnerJoin.
+func InnerJoin(cmp func(a, b []byte) int, left, right Source) *Join {
+	return newJoin(cmp, left, right, false)
+}
+
+// LeftJoin returns a Join which produces every item of left, along with the
+// value of its key in right if one exists.  Both sources must be ordered by
+// cmp.  If cmp is nil bytes.Compare is used.
+func LeftJoin(cmp func(a, b []byte) int, left, right Source) *Join {
+	return newJoin(cmp, left, right, true)
+}
+
+func newJoin(cmp func(a, b []byte) int, left, right Source, outer bool) *Join {
+	if cmp == nil {
+		cmp = bytes.Compare
+	}
+	return &Join{
+		left:  left,
+		right: right,
+		cmp:   cmp,
+		outer: outer,
+	}
+}
+
+// Scan advances j to the next item of the left source which is part of the
+// join.  Scan returns false when the items are exhausted or either source
+// encounters an error.
+func (j *Join) Scan() bool {
+	if j.err != nil {
+		return false
+	}
+	for {
+		j.match = false
+		if !j.left.Scan() {
+			j.err = j.left.Err()
+			return false
+		}
+		k := j.left.Key()
+		if !j.rstarted {
+			j.rstarted = true
+			j.rok = j.right.Scan()
+		}
+		for j.rok && j.cmp(j.right.Key(), k) < 0 {
+			j.rok = j.right.Scan()
+		}
+		if !j.rok {
+			j.err = j.right.Err()
+			if j.err != nil {
+				return false
+			}
+			if !j.outer {
+				// No remaining item of left can be joined.
+				return false
+			}
+		}
+		j.match = j.rok && j.cmp(j.right.Key(), k) == 0
+		if j.match || j.outer {
+			return true
+		}
+	}
+}
+
+// Key returns the key of the current item.
+func (j *Join) Key() []byte {
+	return j.left.Key()
+}
+
+// Val returns the value of the current item in the left source.  Val allows a
+// Join to be used as a Source.
+func (j *Join) Val() []byte {
+	return j.left.Val()
+}
+
+// Left returns the value of the current item in the left source.
+func (j *Join) Left() []byte {
+	return j.left.Val()
+}
+
+// Right returns the value of the current key in the right source, or nil if
+// the right source does not contain the key.
+func (j *Join) Right() []byte {
+	if !j.match {
+		return nil
+	}
+	return j.right.Val()
+}
+
+// Matched returns true if the right source contains the current key.
+// Matched is always true for an InnerJoin.
+func (j *Join) Matched() bool {
+	return j.match
+}
+
+// Err returns the first error encountered by either source.
+func (j *Join) Err() error {
+	return j.err
+}
@@ -0,0 +1,190 @@
+package lmdbscan
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/bmatsuo/lmdb-go/internal/lmdbtest"
+	"github.com/bmatsuo/lmdb-go/lmdb"
+)
+
+func TestMerge(t *testing.T) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	// A second environment shows that sources need not share a transaction.
+	env2, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env2)
+
+	data := []lmdbtest.SimpleItemList{
+		{{K: "a", V: "0"}, {K: "c", V: "0"}, {K: "e", V: "0"}},
+		{{K: "b", V: "1"}, {K: "c", V: "1"}, {K: "f", V: "1"}},
+		{},
+	}
+	var dbis []lmdb.DBI
+	for i, items := range data {
+		dbi, err := lmdbtest.OpenDBI(env, fmt.Sprint("db", i), lmdb.Create)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = lmdbtest.Put(env, dbi, items)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dbis = append(dbis, dbi)
+	}
+	dbi2, err := lmdbtest.OpenRoot(env2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = lmdbtest.Put(env2, dbi2, lmdbtest.SimpleItemList{{K: "a", V: "3"}, {K: "d", V: "3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txn, err := env.BeginTxn(nil, lmdb.Readonly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Abort()
+	txn2, err := env2.BeginTxn(nil, lmdb.Readonly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn2.Abort()
+
+	var srcs []Source
+	for _, dbi := range dbis {
+		s := New(txn, dbi)
+		defer s.Close()
+		srcs = append(srcs, s)
+	}
+	s2 := New(txn2, dbi2)
+	defer s2.Close()
+	srcs = append(srcs, s2)
+
+	m := NewMerge(nil, srcs...)
+	var merged []string
+	for m.Scan() {
+		merged = append(merged, fmt.Sprintf("%s=%s/%d", m.Key(), m.Val(), m.Source()))
+	}
+	if m.Err() != nil {
+		t.Fatal(m.Err())
+	}
+	exp := []string{"a=0/0", "a=3/3", "b=1/1", "c=0/0", "c=1/1", "d=3/3", "e=0/0", "f=1/1"}
+	if !reflect.DeepEqual(merged, exp) {
+		t.Errorf("unexpected items: %q (!= %q)", merged, exp)
+	}
+	if m.Source() != -1 {
+		t.Errorf("unexpected source: %d (!= -1)", m.Source())
+	}
+	if m.Scan() {
+		t.Errorf("scan after end")
+	}
+}
+
+func TestMerge_err(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = lmdbtest.Put(env, dbi, lmdbtest.SimpleItemList{{K: "a", V: "a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		s1 := New(txn, dbi)
+		defer s1.Close()
+		s2 := New(txn, 123)
+		defer s2.Close()
+		m := NewMerge(nil, s1, s2)
+		for m.Scan() {
+			t.Error("loop should not execute")
+		}
+		return m.Err()
+	})
+	if err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestJoin(t *testing.T) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	left, err := lmdbtest.OpenDBI(env, "left", lmdb.Create|lmdb.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	right, err := lmdbtest.OpenDBI(env, "right", lmdb.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = lmdbtest.Put(env, left, lmdbtest.SimpleItemList{
+		{K: "a", V: "l0"},
+		{K: "b", V: "l1"},
+		{K: "b", V: "l2"},
+		{K: "d", V: "l3"},
+		{K: "f", V: "l4"},
+		{K: "g", V: "l5"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = lmdbtest.Put(env, right, lmdbtest.SimpleItemList{
+		{K: "b", V: "r0"},
+		{K: "c", V: "r1"},
+		{K: "d", V: "r2"},
+		{K: "f", V: "r3"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		join func(cmp func(a, b []byte) int, left, right Source) *Join
+		exp  []string
+	}{
+		{InnerJoin, []string{"b=l1,r0", "b=l2,r0", "d=l3,r2", "f=l4,r3"}},
+		{LeftJoin, []string{"a=l0,", "b=l1,r0", "b=l2,r0", "d=l3,r2", "f=l4,r3", "g=l5,"}},
+	} {
+		var joined []string
+		err = env.View(func(txn *lmdb.Txn) (err error) {
+			ls := New(txn, left)
+			defer ls.Close()
+			rs := New(txn, right)
+			defer rs.Close()
+			j := test.join(nil, ls, rs)
+			for j.Scan() {
+				if j.Matched() != (j.Right() != nil) {
+					t.Errorf("%s: unexpected match: %v", j.Key(), j.Matched())
+				}
+				joined = append(joined, fmt.Sprintf("%s=%s,%s", j.Key(), j.Left(), j.Right()))
+			}
+			return j.Err()
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(joined, test.exp) {
+			t.Errorf("unexpected items: %q (!= %q)", joined, test.exp)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package lmdbscan
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/bmatsuo/lmdb-go/lmdb"
+)
+
+// ErrSnapshot is returned by Parallel when ParallelOptions.RequireSnapshot is
+// set and the transactions of the workers could not be opened on a single
+// snapshot of the environment.
+var ErrSnapshot = fmt.Errorf("transactions are not on a single snapshot")
+
+// ParallelOptions controls the partitioning of a database by Parallel.
+type ParallelOptions struct {
+	// Workers is the number of goroutines, and read-only transactions, used
+	// to scan the database.  If Workers is zero runtime.GOMAXPROCS(0) is
+	// used.
+	Workers int
+
+	// Splits is the number of key ranges the database is divided into.
+	// Ranges are handed out to workers as they become idle, so splitting a
+	// database into more ranges than workers balances uneven ranges.  If
+	// Splits is zero four ranges per worker are used.
+	Splits int
+
+	// Retries is the number of times the transactions of the workers are
+	// renewed when a writer commits while they are being opened.  If Retries
+	// is zero three retries are made.
+	Retries int
+
+	// RequireSnapshot causes Parallel to return ErrSnapshot, without calling
+	// fn, if the transactions of the workers do not view the same snapshot of
+	// the environment after Retries attempts.  Otherwise the workers may view
+	// different snapshots in an environment with frequent writers, though the
+	// key ranges scanned always cover the database without overlapping.
+	RequireSnapshot bool
+
+	// Cmp orders split keys and is assigned to each Range passed to fn.  Cmp
+	// must order keys the same way as the database.  If Cmp is nil
+	// bytes.Compare is used.
+	Cmp func(a, b []byte) int
+}
+
+// Parallel divides the keys of dbi into ranges and calls fn with each range
+// concurrently, using several goroutines which each have a read-only
+// transaction.  The ranges begin and end between distinct keys, so all the
+// values of a key in a DupSort database are in one range.  The transaction
+// passed to fn must not be used after fn returns.
+//
+//	err := lmdbscan.Parallel(env, dbi, nil, func(txn *lmdb.Txn, r *lmdbscan.Range) error {
+//		s := lmdbscan.NewRange(txn, dbi, r)
+//		defer s.Close()
+//		for s.Scan() {
+//			// ...
+//		}
+//		return s.Err()
+//	})
+//
+// Split keys are found by positioning a cursor at keys interpolated between
+// the first and last keys in the database, with the number of splits limited
+// by the number of leaf pages reported by Txn.Stat.  Interpolation divides
+// databases with evenly distributed keys well.  In other databases ranges may
+// differ in size, which is mitigated by ParallelOptions.Splits.
+//
+// If fn returns an error no further ranges are scanned and the first error is
+// returned once all workers have stopped.
+func Parallel(env *lmdb.Env, dbi lmdb.DBI, opt *ParallelOptions, fn func(txn *lmdb.Txn, r *Range) error) error {
+	var p ParallelOptions
+	if opt != nil {
+		p = *opt
+	}
+	if p.Workers <= 0 {
+		p.Workers = runtime.GOMAXPROCS(0)
+	}
+	if p.Splits <= 0 {
+		p.Splits = 4 * p.Workers
+	}
+	if p.Retries <= 0 {
+		p.Retries = 3
+	}
+	if p.Cmp == nil {
+		p.Cmp = bytes.Compare
+	}
+
+	// The environment is always opened with lmdb.NoTLS so the transactions
+	// can be opened here and passed to the workers.
+	txns, err := beginSnapshot(env, p.Workers, p.Retries)
+	for _, txn := range txns {
+		defer txn.Abort()
+	}
+	if err == ErrSnapshot && !p.RequireSnapshot {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+
+	splits, err := splitKeys(txns[0], dbi, p.Splits, p.Cmp)
+	if err != nil {
+		return err
+	}
+	ranges := make(chan *Range, len(splits)+1)
+	var start []byte
+	for _, k := range splits {
+		ranges <- &Range{Start: start, End: k, Cmp: p.Cmp}
+		start = k
+	}
+	ranges <- &Range{Start: start, Cmp: p.Cmp}
+	close(ranges)
+
+	var wg sync.WaitGroup
+	var mut sync.Mutex
+	var first error
+	failed := func() bool {
+		mut.Lock()
+		defer mut.Unlock()
+		return first != nil
+	}
+	for _, txn := range txns {
+		wg.Add(1)
+		go func(txn *lmdb.Txn) {
+			defer wg.Done()
+			for r := range ranges {
+				if failed() {
+					return
+				}
+				err := fn(txn, r)
+				if err != nil {
+					mut.Lock()
+					if first == nil {
+						first = err
+					}
+					mut.Unlock()
+					return
+				}
+			}
+		}(txn)
+	}
+	wg.Wait()
+	return first
+}
+
+// beginSnapshot begins n read-only transactions.  If a writer commits while
+// the transactions are being opened they are renewed, up to retries times, so
+// that they all view the same snapshot.  If the transactions still differ
+// they are returned along with ErrSnapshot.  The caller must abort the
+// returned transactions, even if an error is returned.
+func beginSnapshot(env *lmdb.Env, n int, retries int) ([]*lmdb.Txn, error) {
+	var txns []*lmdb.Txn
+	for i := 0; i < n; i++ {
+		txn, err := env.BeginTxn(nil, lmdb.Readonly)
+		if err != nil {
+			return txns, err
+		}
+		txns = append(txns, txn)
+	}
+	for i := 0; ; i++ {
+		same := true
+		for _, txn := range txns[1:] {
+			if txn.ID() != txns[0].ID() {
+				same = false
+			}
+		}
+		if same {
+			return txns, nil
+		}
+		if i >= retries {
+			return txns, ErrSnapshot
+		}
+		for _, txn := range txns {
+			txn.Reset()
+		}
+		for _, txn := range txns {
+			err := txn.Renew()
+			if err != nil {
+				return txns, err
+			}
+		}
+	}
+}
+
+// splitKeys returns up to n-1 keys of dbi, in ascending order, which divide
+// the database into n ranges.  Candidate keys are interpolated between the
+// first and last keys of the database, treating the eight bytes following
+// their common prefix as an integer, and the cursor is positioned at each
+// candidate to find a key in the database.
+func splitKeys(txn *lmdb.Txn, dbi lmdb.DBI, n int, cmp func(a, b []byte) int) ([][]byte, error) {
+	stat, err := txn.Stat(dbi)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(n) > stat.LeafPages {
+		n = int(stat.LeafPages)
+	}
+	if n <= 1 {
+		return nil, nil
+	}
+
+	cur, err := txn.OpenCursor(dbi)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close()
+	first, _, err := cur.Get(nil, nil, lmdb.First)
+	if err != nil {
+		return nil, err
+	}
+	last, _, err := cur.Get(nil, nil, lmdb.Last)
+	if err != nil {
+		return nil, err
+	}
+
+	var prefix int
+	for prefix < len(first) && prefix < len(last) && first[prefix] == last[prefix] {
+		prefix++
+	}
+	lo := keyUint64(first[prefix:])
+	hi := keyUint64(last[prefix:])
+	if lo > hi {
+		// The database does not order keys byte-wise.
+		lo, hi = hi, lo
+	}
+	d := hi - lo
+	if d == 0 {
+		return nil, nil
+	}
+
+	var keys [][]byte
+	for i := 1; i < n; i++ {
+		v := lo + d/uint64(n)*uint64(i) + d%uint64(n)*uint64(i)/uint64(n)
+		k := make([]byte, prefix+8)
+		copy(k, first[:prefix])
+		binary.BigEndian.PutUint64(k[prefix:], v)
+		k, _, err = cur.Get(k, nil, lmdb.SetRange)
+		if lmdb.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	sort.Sort(&keySorter{keys, cmp})
+	splits := keys[:0]
+	for _, k := range keys {
+		if cmp(k, first) <= 0 {
+			continue
+		}
+		if len(splits) > 0 && cmp(k, splits[len(splits)-1]) == 0 {
+			continue
+		}
+		splits = append(splits, k)
+	}
+	return splits, nil
+}
+
+// keyUint64 interprets the first eight bytes of k as a big-endian integer,
+// padding k with zeros if it is shorter.
+func keyUint64(k []byte) uint64 {
+	var b [8]byte
+	copy(b[:], k)
+	return binary.BigEndian.Uint64(b[:])
+}
+
+// keySorter sorts keys using a comparison function.
+type keySorter struct {
+	keys [][]byte
+	cmp  func(a, b []byte) int
+}
+
+func (s *keySorter) Len() int           { return len(s.keys) }
+func (s *keySorter) Less(i, j int) bool { return s.cmp(s.keys[i], s.keys[j]) < 0 }
+func (s *keySorter) Swap(i, j int)      { s.keys[i], s.keys[j] = s.keys[j], s.keys[i] }
@@ -0,0 +1,194 @@
+package lmdbscan
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/bmatsuo/lmdb-go/internal/lmdbtest"
+	"github.com/bmatsuo/lmdb-go/lmdb"
+)
+
+func TestParallel(t *testing.T) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenDBI(env, "plain", lmdb.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbidup, err := lmdbtest.OpenDBI(env, "dup", lmdb.Create|lmdb.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var items, dups lmdbtest.SimpleItemList
+	for i := 0; i < 5000; i++ {
+		k := fmt.Sprintf("key%05d", i*7)
+		items = append(items, &lmdbtest.SimpleItem{K: k, V: k})
+		if i%10 == 0 {
+			for j := 0; j < 3; j++ {
+				dups = append(dups, &lmdbtest.SimpleItem{K: k, V: fmt.Sprint(j)})
+			}
+		}
+	}
+	err = lmdbtest.Put(env, dbi, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = lmdbtest.Put(env, dbidup, dups)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		dbi   lmdb.DBI
+		items lmdbtest.SimpleItemList
+	}{
+		{dbi, items},
+		{dbidup, dups},
+	} {
+		var mut sync.Mutex
+		var ranges []*Range
+		var scanned []string
+		err = Parallel(env, test.dbi, &ParallelOptions{Workers: 4}, func(txn *lmdb.Txn, r *Range) error {
+			s := NewRange(txn, test.dbi, r)
+			defer s.Close()
+			var k []string
+			for s.Scan() {
+				k = append(k, fmt.Sprintf("%s=%s", s.Key(), s.Val()))
+			}
+			mut.Lock()
+			ranges = append(ranges, r)
+			scanned = append(scanned, k...)
+			mut.Unlock()
+			return s.Err()
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ranges) < 2 {
+			t.Errorf("database was not split: %d ranges", len(ranges))
+		}
+		var exp []string
+		for _, item := range test.items {
+			exp = append(exp, fmt.Sprintf("%s=%s", item.K, item.V))
+		}
+		sort.Strings(scanned)
+		if !reflect.DeepEqual(scanned, exp) {
+			t.Errorf("unexpected items scanned (%d items, expected %d)", len(scanned), len(exp))
+		}
+	}
+}
+
+func TestParallel_err(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var items lmdbtest.SimpleItemList
+	for i := 0; i < 1000; i++ {
+		k := fmt.Sprintf("%04d", i)
+		items = append(items, &lmdbtest.SimpleItem{K: k, V: k})
+	}
+	err = lmdbtest.Put(env, dbi, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errStop := fmt.Errorf("stop")
+	err = Parallel(env, dbi, &ParallelOptions{Workers: 2}, func(txn *lmdb.Txn, r *Range) error {
+		return errStop
+	})
+	if err != errStop {
+		t.Errorf("unexpected error: %v (!= %v)", err, errStop)
+	}
+
+	err = Parallel(env, 123, nil, func(txn *lmdb.Txn, r *Range) error {
+		t.Error("function should not be called")
+		return nil
+	})
+	if err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestParallel_empty(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	var mut sync.Mutex
+	err = Parallel(env, dbi, &ParallelOptions{RequireSnapshot: true}, func(txn *lmdb.Txn, r *Range) error {
+		mut.Lock()
+		n++
+		mut.Unlock()
+		if r.Start != nil || r.End != nil {
+			t.Errorf("unexpected range: %q %q", r.Start, r.End)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("unexpected number of ranges: %d (!= 1)", n)
+	}
+}
+
+func TestSplitKeys(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var items lmdbtest.SimpleItemList
+	for i := 0; i < 256; i++ {
+		k := string([]byte{'k', byte(i)})
+		items = append(items, &lmdbtest.SimpleItem{K: k, V: string(make([]byte, 100))})
+	}
+	err = lmdbtest.Put(env, dbi, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		splits, err := splitKeys(txn, dbi, 4, bytes.Compare)
+		if err != nil {
+			return err
+		}
+		exp := [][]byte{{'k', 64}, {'k', 128}, {'k', 192}}
+		if !reflect.DeepEqual(splits, exp) {
+			t.Errorf("unexpected splits: %q (!= %q)", splits, exp)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,240 @@
+package lmdbscan
+
+import (
+	"bytes"
+
+	"github.com/bmatsuo/lmdb-go/lmdb"
+)
+
+// Range describes a range of keys in a database and the order in which they
+// are scanned.  The zero Range contains every key in a database.
+//
+// By default a Range includes Start and excludes End, as in [Start, End).
+// The ExcludeStart and IncludeEnd fields change the treatment of the bounds.
+// A nil Start or End leaves the corresponding side of the Range unbounded.
+type Range struct {
+	Start        []byte
+	End          []byte
+	ExcludeStart bool
+	IncludeEnd   bool
+
+	// Prefix restricts the Range to keys beginning with Prefix, in addition
+	// to any bounds given by Start and End.  Keys are compared to Prefix
+	// byte-wise so Prefix should only be used with databases which order
+	// keys lexicographically.
+	Prefix []byte
+
+	// Reverse scans the Range in descending order, from End to Start.  In
+	// a DupSort database the duplicates of each key are also scanned in
+	// descending order.
+	Reverse bool
+
+	// Offset is the number of items in the Range which are skipped before
+	// scanning begins, and Limit is the maximum number of items scanned.  A
+	// zero Limit does not limit the number of items.  Each duplicate in a
+	// DupSort database is counted as an item.
+	Offset int
+	Limit  int
+
+	// Cmp compares keys to Start and End.  Cmp must order keys the same way
+	// as the database.  If Cmp is nil bytes.Compare is used, which is only
+	// correct for databases which use the default comparison function.
+	Cmp func(a, b []byte) int
+}
+
+// RangeScanner scans the items in a Range.
+type RangeScanner struct {
+	s       *Scanner
+	r       Range
+	dup     bool
+	next    uint
+	n       int
+	started bool
+	done    bool
+}
+
+// NewRange allocates and initializes a RangeScanner for the keys of dbi
+// within txn which are contained in r.  When the RangeScanner is no longer
+// needed its Close method must be called.
+func NewRange(txn *lmdb.Txn, dbi lmdb.DBI, r *Range) *RangeScanner {
+	rs := &RangeScanner{
+		s:    New(txn, dbi),
+		next: lmdb.Next,
+	}
+	if r != nil {
+		rs.r = *r
+	}
+	if rs.r.Cmp == nil {
+		rs.r.Cmp = bytes.Compare
+	}
+	if rs.r.Reverse {
+		rs.next = lmdb.Prev
+	}
+	if rs.s.err == nil {
+		var flags uint
+		flags, rs.s.err = txn.Flags(dbi)
+		rs.dup = flags&lmdb.DupSort != 0
+	}
+	return rs
+}
+
+// Cursor returns the lmdb.Cursor underlying rs.  Cursor returns nil if rs is
+// closed.
+func (rs *RangeScanner) Cursor() *lmdb.Cursor {
+	return rs.s.Cursor()
+}
+
+// Key returns the key read during the last call to Scan.
+func (rs *RangeScanner) Key() []byte {
+	return rs.s.Key()
+}
+
+// Val returns the value read during the last call to Scan.
+func (rs *RangeScanner) Val() []byte {
+	return rs.s.Val()
+}
+
+// Scan advances rs to the next item in its Range.  Scan returns false when
+// the items in the Range are exhausted, the Limit is reached, or an error is
+// encountered.
+func (rs *RangeScanner) Scan() bool {
+	if rs.done || rs.s.err != nil && !rs.started {
+		return false
+	}
+	var ok bool
+	if !rs.started {
+		rs.started = true
+		ok = rs.seek() && rs.s.Scan()
+		for i := 0; ok && i < rs.r.Offset; i++ {
+			ok = rs.s.Scan() && !rs.beyond(rs.s.key)
+		}
+	} else {
+		ok = rs.s.Scan()
+	}
+	if !ok || rs.beyond(rs.s.key) || rs.r.Limit > 0 && rs.n >= rs.r.Limit {
+		rs.done = true
+		rs.s.key, rs.s.val = nil, nil
+		return false
+	}
+	rs.n++
+	return true
+}
+
+// seek positions the cursor at the first item in the Range so that it is
+// returned by the next call to rs.s.Scan.
+func (rs *RangeScanner) seek() bool {
+	if rs.r.Reverse {
+		return rs.seekReverse()
+	}
+	start := rs.r.Start
+	if rs.r.Prefix != nil && (start == nil || rs.r.Cmp(rs.r.Prefix, start) > 0) {
+		start = rs.r.Prefix
+	}
+	var ok bool
+	if len(start) == 0 {
+		ok = rs.s.Set(nil, nil, lmdb.First)
+	} else {
+		ok = rs.s.Set(start, nil, lmdb.SetRange)
+	}
+	for ok && rs.before(rs.s.key) {
+		ok = rs.s.Set(nil, nil, lmdb.NextNoDup)
+	}
+	rs.s.op = rs.next
+	return ok
+}
+
+// seekReverse positions the cursor at the last item in the Range.  SetRange
+// finds the first key which is not less than the end of the range, which
+// may be past the range (or may not exist), so the cursor is moved backwards
+// from there.
+func (rs *RangeScanner) seekReverse() bool {
+	end := rs.r.End
+	if rs.r.Prefix != nil {
+		pend := PrefixEnd(rs.r.Prefix)
+		if pend != nil && (end == nil || rs.r.Cmp(pend, end) < 0) {
+			end = pend
+		}
+	}
+	var ok bool
+	switch {
+	case end == nil:
+	case len(end) == 0:
+		// No key is less than an empty key.
+		ok = rs.s.Set(nil, nil, lmdb.First)
+	default:
+		ok = rs.s.Set(end, nil, lmdb.SetRange)
+	}
+	if !ok {
+		if rs.s.err != nil && !lmdb.IsNotFound(rs.s.err) {
+			return false
+		}
+		ok = rs.s.Set(nil, nil, lmdb.Last)
+	}
+	for ok && rs.after(rs.s.key) {
+		ok = rs.s.Set(nil, nil, lmdb.PrevNoDup)
+	}
+	if ok && rs.dup {
+		// SetRange positions the cursor at the first duplicate of a key.
+		// LastDup does not return the key so the item is read again.
+		ok = rs.s.Set(nil, nil, lmdb.LastDup) && rs.s.Set(nil, nil, lmdb.GetCurrent)
+	}
+	rs.s.op = rs.next
+	return ok
+}
+
+// before returns true if k precedes the Range in key order.
+func (rs *RangeScanner) before(k []byte) bool {
+	if rs.r.Start != nil {
+		c := rs.r.Cmp(k, rs.r.Start)
+		if c < 0 || c == 0 && rs.r.ExcludeStart {
+			return true
+		}
+	}
+	return rs.r.Prefix != nil && !bytes.HasPrefix(k, rs.r.Prefix) && bytes.Compare(k, rs.r.Prefix) < 0
+}
+
+// after returns true if k follows the Range in key order.
+func (rs *RangeScanner) after(k []byte) bool {
+	if rs.r.End != nil {
+		c := rs.r.Cmp(k, rs.r.End)
+		if c > 0 || c == 0 && !rs.r.IncludeEnd {
+			return true
+		}
+	}
+	return rs.r.Prefix != nil && !bytes.HasPrefix(k, rs.r.Prefix) && bytes.Compare(k, rs.r.Prefix) > 0
+}
+
+// beyond returns true if k is past the Range in the direction of the scan.
+func (rs *RangeScanner) beyond(k []byte) bool {
+	if rs.r.Reverse {
+		return rs.before(k)
+	}
+	return rs.after(k)
+}
+
+// Err returns a non-nil error if and only if the previous call to Scan
+// resulted in an error other than lmdb.ErrNotFound.
+func (rs *RangeScanner) Err() error {
+	return rs.s.Err()
+}
+
+// Close closes the cursor underlying rs.  Close does not attempt to
+// terminate the enclosing transaction.
+//
+// Scan must not be called after Close.
+func (rs *RangeScanner) Close() {
+	rs.s.Close()
+}
+
+// PrefixEnd returns the smallest key greater than every key beginning with
+// prefix, or nil if there is no such key.
+func PrefixEnd(prefix []byte) []byte {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != 0xff {
+			end := append([]byte(nil), prefix[:i+1]...)
+			end[i]++
+			return end
+		}
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package lmdbscan
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/bmatsuo/lmdb-go/internal/lmdbtest"
+	"github.com/bmatsuo/lmdb-go/lmdb"
+)
+
+func TestRangeScanner(t *testing.T) {
+	env, err := lmdbtest.NewEnv(&lmdbtest.EnvOptions{MaxDBs: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenDBI(env, "plain", lmdb.Create)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbidup, err := lmdbtest.OpenDBI(env, "dup", lmdb.Create|lmdb.DupSort)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var items, dups lmdbtest.SimpleItemList
+	for _, p := range []string{"a", "b", "c"} {
+		for i := 0; i < 30; i += 2 {
+			k := fmt.Sprintf("%s%02d", p, i)
+			items = append(items, &lmdbtest.SimpleItem{K: k, V: k})
+			for j := 0; j < 3; j++ {
+				dups = append(dups, &lmdbtest.SimpleItem{K: k, V: fmt.Sprint(j)})
+			}
+		}
+	}
+	err = lmdbtest.Put(env, dbi, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = lmdbtest.Put(env, dbidup, dups)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := []string{"", "a", "a00", "a01", "a28", "a29", "b", "b13", "b14", "b15", "bz", "c", "c28", "d", "\xff"}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		r := &Range{
+			ExcludeStart: rng.Intn(2) == 0,
+			IncludeEnd:   rng.Intn(2) == 0,
+			Reverse:      rng.Intn(2) == 0,
+		}
+		if rng.Intn(4) > 0 {
+			r.Start = []byte(keys[rng.Intn(len(keys))])
+		}
+		if rng.Intn(4) > 0 {
+			r.End = []byte(keys[rng.Intn(len(keys))])
+		}
+		if rng.Intn(3) == 0 {
+			r.Prefix = []byte(keys[rng.Intn(len(keys))])
+		}
+		if rng.Intn(3) == 0 {
+			r.Offset = rng.Intn(5)
+		}
+		if rng.Intn(3) == 0 {
+			r.Limit = rng.Intn(5)
+		}
+
+		for _, test := range []struct {
+			dbi   lmdb.DBI
+			items lmdbtest.SimpleItemList
+		}{
+			{dbi, items},
+			{dbidup, dups},
+		} {
+			var scanned lmdbtest.SimpleItemList
+			err = env.View(func(txn *lmdb.Txn) (err error) {
+				s := NewRange(txn, test.dbi, r)
+				defer s.Close()
+				for s.Scan() {
+					scanned = append(scanned, &lmdbtest.SimpleItem{
+						K: string(s.Key()),
+						V: string(s.Val()),
+					})
+				}
+				return s.Err()
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			exp := filterRange(test.items, r)
+			if !reflect.DeepEqual(scanned, exp) {
+				t.Errorf("range %+v: unexpected items %v (!= %v)", r, scanned, exp)
+			}
+		}
+	}
+}
+
+// filterRange returns the items of a database contained in r, in the order
+// they are scanned.
+func filterRange(items lmdbtest.SimpleItemList, r *Range) lmdbtest.SimpleItemList {
+	var filtered lmdbtest.SimpleItemList
+	for _, item := range items {
+		k := []byte(item.K)
+		if r.Start != nil {
+			c := bytes.Compare(k, r.Start)
+			if c < 0 || c == 0 && r.ExcludeStart {
+				continue
+			}
+		}
+		if r.End != nil {
+			c := bytes.Compare(k, r.End)
+			if c > 0 || c == 0 && !r.IncludeEnd {
+				continue
+			}
+		}
+		if !bytes.HasPrefix(k, r.Prefix) {
+			continue
+		}
+		filtered = append(filtered, item)
+	}
+	if r.Reverse {
+		for i, j := 0, len(filtered)-1; i < j; i, j = i+1, j-1 {
+			filtered[i], filtered[j] = filtered[j], filtered[i]
+		}
+	}
+	if r.Offset >= len(filtered) {
+		return nil
+	}
+	filtered = filtered[r.Offset:]
+	if r.Limit > 0 && r.Limit < len(filtered) {
+		filtered = filtered[:r.Limit]
+	}
+	return filtered
+}
+
+func TestRangeScanner_err(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	err = env.View(func(txn *lmdb.Txn) (err error) {
+		s := NewRange(txn, 123, nil)
+		defer s.Close()
+		for s.Scan() {
+			t.Error("loop should not execute")
+		}
+		return s.Err()
+	})
+	if err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestPrefixEnd(t *testing.T) {
+	for _, test := range []struct {
+		prefix, end []byte
+	}{
+		{nil, nil},
+		{[]byte("\xff\xff"), nil},
+		{[]byte("ab"), []byte("ac")},
+		{[]byte("a\xff"), []byte("b")},
+	} {
+		end := PrefixEnd(test.prefix)
+		if !bytes.Equal(end, test.end) {
+			t.Errorf("%q: unexpected end: %q (!= %q)", test.prefix, end, test.end)
+		}
+	}
+}
@@ -16,13 +16,15 @@ var errClosed = fmt.Errorf("scanner is closed")
 // Scanner is a low level construct for scanning databases inside a
 // transaction.
 type Scanner struct {
-	dbi lmdb.DBI
-	cur *lmdb.Cursor
-	op  uint
-	key []byte
-	val []byte
-	err error
-	set bool
+	dbi   lmdb.DBI
+	cur   *lmdb.Cursor
+	op    uint
+	key   []byte
+	val   []byte
+	err   error
+	set   bool
+	batch *lmdb.RecordBatch
+	pos   int
 }
 
 // New allocates and intializes a Scanner for dbi within txn.  When the Scanner
@@ -37,6 +39,22 @@ func New(txn *lmdb.Txn, dbi lmdb.DBI) *Scanner {
 	return s
 }
 
+// NewBatch is like New but the returned Scanner retrieves up to size records
+// at a time using lmdb.Cursor.GetBatch, which amortizes the cost of calling C
+// over many records.  The batch is refilled transparently as the Scanner
+// advances.
+//
+// Because records are read ahead of the Scanner the cursor underlying a batch
+// Scanner is not positioned at s.Key() and must not be used to modify the
+// database (e.g. with Cursor().Del()).  Calling Set or SetNext discards any
+// records remaining in the current batch.
+func NewBatch(txn *lmdb.Txn, dbi lmdb.DBI, size int) *Scanner {
+	s := New(txn, dbi)
+	s.batch = lmdb.NewRecordBatch(size)
+	s.pos = -1
+	return s
+}
+
 // Cursor returns the lmdb.Cursor underlying s.  Cursor returns nil if s is
 // closed.
 func (s *Scanner) Cursor() *lmdb.Cursor {
@@ -71,6 +89,7 @@ func (s *Scanner) Set(k, v []byte, opset uint) bool {
 		return false
 	}
 	s.set = true
+	s.pos = -1
 	s.key, s.val, s.err = s.cur.Get(k, v, opset)
 	return s.err == nil
 }
@@ -96,12 +115,32 @@ func (s *Scanner) Scan() bool {
 	}
 	if s.set {
 		s.set = false
+	} else if s.batch != nil {
+		s.scanBatch()
 	} else {
 		s.key, s.val, s.err = s.cur.Get(nil, nil, s.op)
 	}
 	return s.err == nil
 }
 
+// scanBatch advances s to the next record in its batch, retrieving a new
+// batch when the current one is exhausted.
+func (s *Scanner) scanBatch() {
+	if s.pos >= 0 && s.pos+1 < s.batch.Len() {
+		s.pos++
+	} else {
+		s.pos = 0
+		s.err = s.cur.GetBatch(s.op, s.batch)
+		if s.err != nil {
+			s.pos = -1
+			s.key, s.val = nil, nil
+			return
+		}
+	}
+	s.key = s.batch.Key(s.pos)
+	s.val = s.batch.Val(s.pos)
+}
+
 func (s *Scanner) checkOpen() bool {
 	if s.cur != nil {
 		return true
@@ -1,6 +1,7 @@
 package lmdbscan
 
 import (
+	"fmt"
 	"reflect"
 	"syscall"
 	"testing"
@@ -105,6 +106,62 @@ func TestScanner_Scan(t *testing.T) {
 	}
 }
 
+func TestScanner_batch(t *testing.T) {
+	env, err := lmdbtest.NewEnv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lmdbtest.Destroy(env)
+
+	dbi, err := lmdbtest.OpenRoot(env, 0)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	var items lmdbtest.SimpleItemList
+	for i := 0; i < 10; i++ {
+		items = append(items, &lmdbtest.SimpleItem{
+			K: fmt.Sprintf("k%d", i),
+			V: fmt.Sprintf("v%d", i),
+		})
+	}
+	err = lmdbtest.Put(env, dbi, items)
+	if err != nil {
+		t.Error(err)
+	}
+
+	for _, size := range []int{1, 3, 10, 20} {
+		var scanned, tail lmdbtest.SimpleItemList
+		err = env.View(func(txn *lmdb.Txn) (err error) {
+			s := NewBatch(txn, dbi, size)
+			defer s.Close()
+			scanned, err = remaining(s)
+			if err != nil {
+				return err
+			}
+
+			s = NewBatch(txn, dbi, size)
+			defer s.Close()
+			if !s.Scan() || !s.Scan() {
+				return s.Err()
+			}
+			s.Set([]byte("k45"), nil, lmdb.SetRange)
+			tail, err = remaining(s)
+			return err
+		})
+		if err != nil {
+			t.Errorf("size %d: %v", size, err)
+		}
+		if !reflect.DeepEqual(scanned, items) {
+			t.Errorf("size %d: unexpected items %q (!= %q)", size, scanned, items)
+		}
+		if !reflect.DeepEqual(tail, items[5:]) {
+			t.Errorf("size %d: unexpected items %q (!= %q)", size, tail, items[5:])
+		}
+	}
+}
+
 func TestScanner_Set(t *testing.T) {
 	env, err := lmdbtest.NewEnv(nil)
 	if err != nil {